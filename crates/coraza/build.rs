@@ -40,5 +40,5 @@ fn main() {
     println!("cargo:rustc-link-lib=dylib=coraza_bridge");
 
     // Rebuild when Go source changes
-    println!("cargo:rerun-if-changed=go/main.go");
+    println!("cargo:rerun-if-changed=go");
 }
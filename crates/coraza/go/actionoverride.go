@@ -0,0 +1,133 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// actionOverride is the disruptive action an interruption is rewritten to.
+type actionOverride struct {
+	action string
+	// status replaces the interruption's status when not 0.
+	status int
+	// data is the redirect target.
+	data string
+}
+
+// parseActionOverride parses the target of coraza_set_action_override:
+// "deny", "deny:<status>", "drop" or "redirect:<url>".
+func parseActionOverride(to string) (actionOverride, error) {
+	action, arg, _ := strings.Cut(to, ":")
+	switch action {
+	case "deny":
+		if arg == "" {
+			return actionOverride{action: action}, nil
+		}
+		status, err := strconv.Atoi(arg)
+		if err != nil || !validStatus(C.int(status)) {
+			return actionOverride{}, fmt.Errorf("invalid deny status %q", arg)
+		}
+		return actionOverride{action: action, status: status}, nil
+	case "drop":
+		if arg != "" {
+			return actionOverride{}, fmt.Errorf("drop takes no argument")
+		}
+		return actionOverride{action: action}, nil
+	case "redirect":
+		if arg == "" {
+			return actionOverride{}, fmt.Errorf("redirect requires a URL")
+		}
+		return actionOverride{action: action, data: arg}, nil
+	}
+	return actionOverride{}, fmt.Errorf("unsupported action %q", action)
+}
+
+// coraza_set_action_override makes this WAF report interruptions with the
+// disruptive action from ("deny", "drop" or "redirect") as to instead: "deny",
+// "deny:<status>", "drop" or "redirect:<url>". A redirect keeps a 3xx rule
+// status and otherwise uses 302. The bridge's own interruptions (rule 0) are
+// not overridden, and overrides are not chained. An empty or NULL to removes
+// the override. Returns 0 on success or -1 for an unknown WAF or an invalid
+// action (see coraza_last_error).
+//
+//export coraza_set_action_override
+func coraza_set_action_override(wafID C.uint64_t, from, to *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	src := C.GoString(from)
+	if src != "deny" && src != "drop" && src != "redirect" {
+		setLastError(fmt.Errorf("unsupported action %q", src))
+		return -1
+	}
+	var (
+		o   actionOverride
+		err error
+	)
+	if to != nil && C.GoString(to) != "" {
+		if o, err = parseActionOverride(C.GoString(to)); err != nil {
+			setLastError(err)
+			return -1
+		}
+	}
+
+	ws.actionOverridesMu.Lock()
+	defer ws.actionOverridesMu.Unlock()
+	next := make(map[string]actionOverride)
+	if cur := ws.actionOverrides.Load(); cur != nil {
+		for k, v := range *cur {
+			next[k] = v
+		}
+	}
+	if o.action == "" {
+		delete(next, src)
+	} else {
+		next[src] = o
+	}
+	ws.actionOverrides.Store(&next)
+	return 0
+}
+
+// overrideAction rewrites it in place according to the WAF's action
+// overrides, unless it is one of the bridge's own interruptions (rule 0).
+// Coraza allocates a new interruption for every disruptive action, so the
+// change is confined to this transaction.
+func (ws *wafState) overrideAction(it *types.Interruption) {
+	m := ws.actionOverrides.Load()
+	if m == nil || it.RuleID == 0 {
+		return
+	}
+	o, ok := (*m)[it.Action]
+	if !ok {
+		return
+	}
+	it.Action = o.action
+	it.Data = o.data
+	switch o.action {
+	case "deny":
+		if o.status != 0 {
+			it.Status = o.status
+		}
+	case "redirect":
+		it.Status = redirectStatus(it.Status)
+	}
+}
+
+// redirectStatus returns the status a redirect with the given rule status
+// is issued with: the status if it is a 3xx, else 302.
+func redirectStatus(status int) int {
+	if status < 300 || status > 399 {
+		return 302
+	}
+	return status
+}
@@ -0,0 +1,129 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "github.com/corazawaf/coraza/v3/types"
+
+type anomalyScores struct {
+	Inbound           *int `json:"inbound,omitempty"`
+	Outbound          *int `json:"outbound,omitempty"`
+	InboundThreshold  *int `json:"inbound_threshold,omitempty"`
+	OutboundThreshold *int `json:"outbound_threshold,omitempty"`
+}
+
+// coraza_get_anomaly_scores_json returns the CRS inbound and outbound anomaly
+// scores and their thresholds from the TX collection as a JSON object. Values
+// that are not set are omitted, so "{}" means no scoring took place. The
+// caller must free the returned string.
+//
+//export coraza_get_anomaly_scores_json
+func coraza_get_anomaly_scores_json(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	return jsonCString(txAnomalyScores(tx))
+}
+
+func txAnomalyScores(tx types.Transaction) anomalyScores {
+	lookup := func(keys ...string) *int {
+		if v, ok := txIntVar(tx, keys...); ok {
+			return &v
+		}
+		return nil
+	}
+	return anomalyScores{
+		// CRS v4 names first, then the v3 equivalents.
+		Inbound:           lookup("blocking_inbound_anomaly_score", "inbound_anomaly_score", "anomaly_score"),
+		Outbound:          lookup("blocking_outbound_anomaly_score", "outbound_anomaly_score"),
+		InboundThreshold:  lookup("inbound_anomaly_score_threshold"),
+		OutboundThreshold: lookup("outbound_anomaly_score_threshold"),
+	}
+}
+
+// crsSeverityScores are the TX variables holding the anomaly score CRS adds
+// for a match of each severity, with the CRS defaults.
+var crsSeverityScores = map[types.RuleSeverity]struct {
+	key   string
+	value int
+}{
+	types.RuleSeverityCritical: {"critical_anomaly_score", 5},
+	types.RuleSeverityError:    {"error_anomaly_score", 4},
+	types.RuleSeverityWarning:  {"warning_anomaly_score", 3},
+	types.RuleSeverityNotice:   {"notice_anomaly_score", 2},
+}
+
+// scoreContributor is one element of coraza_get_score_contributors_json.
+type scoreContributor struct {
+	RuleID       int    `json:"rule_id"`
+	Message      string `json:"message"`
+	SeverityText string `json:"severityText"`
+	Direction    string `json:"direction"`
+	Score        int    `json:"score"`
+}
+
+// coraza_get_score_contributors_json attributes the CRS anomaly scores to the
+// matched rules, as a JSON array of {rule_id, message, severityText,
+// direction, score} in match order, using the CRS per-severity scores in TX.
+// The breakdown is derived rather than traced. Returns "[]" when no scoring
+// rule matched, or nil for an unknown handle. The caller must free the
+// returned string.
+//
+//export coraza_get_score_contributors_json
+func coraza_get_score_contributors_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	tx := st.tx
+
+	contributors := []scoreContributor{}
+	for _, mr := range tx.MatchedRules() {
+		rule := mr.Rule()
+		severity, ok := ruleSeverity(rule)
+		score, scored := crsSeverityScores[severity]
+		if !ok || !scored || mr.Message() == "" {
+			continue
+		}
+		if v, ok := txIntVar(tx, score.key); ok {
+			score.value = v
+		}
+		direction := "inbound"
+		if rule.Phase() >= types.PhaseResponseHeaders {
+			direction = "outbound"
+		}
+		contributors = append(contributors, scoreContributor{
+			RuleID:       rule.ID(),
+			Message:      st.maskMatched(mr, mr.Message()),
+			SeverityText: severity.String(),
+			Direction:    direction,
+			Score:        score.value,
+		})
+	}
+	return jsonCString(contributors)
+}
+
+// coraza_set_outbound_anomaly_threshold overrides the CRS outbound anomaly
+// score threshold (TX:outbound_anomaly_score_threshold) for this transaction,
+// e.g. to be stricter on responses from a sensitive backend. The value is
+// written when the response headers phase starts, after CRS has initialised
+// its defaults, so the response phase rules interrupt once the outbound score
+// reaches it. Returns 0 on success or -1 for an unknown handle, a
+// non-positive threshold or a response already being processed.
+//
+//export coraza_set_outbound_anomaly_threshold
+func coraza_set_outbound_anomaly_threshold(txID C.uint64_t, threshold C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || threshold <= 0 {
+		return -1
+	}
+	if vars, ok := txVariables(st.tx); ok && vars.ResponseStatus().Get() != "" {
+		return -1
+	}
+	st.outboundThreshold = int(threshold)
+	return 0
+}
@@ -0,0 +1,162 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// argLimits bounds the arguments a request may carry; 0 means no limit.
+type argLimits struct {
+	maxArgs     int
+	maxTotalLen int64
+}
+
+// argCounter tracks the '&'-separated arguments of the query string and a
+// URL-encoded body as their bytes go by, without parsing them.
+type argCounter struct {
+	count  int
+	length int64
+	// open is set while inside an argument list, so its first byte starts
+	// a new argument.
+	open bool
+	// truncated is set once a limit was hit.
+	truncated bool
+}
+
+// argsTruncatedVar is the TX variable set when a request exceeds the limits.
+const argsTruncatedVar = "args_truncated"
+
+// coraza_set_arg_limits caps the arguments parsed from the query string and
+// URL-encoded request bodies of this WAF's new transactions, including those
+// passed to coraza_process_request_body_json, at maxArgs arguments and
+// maxTotalLen bytes. Arguments past a limit are dropped, TX:args_truncated is
+// set and, with SecRuleEngine On, the transaction is interrupted with status
+// 400. Pass 0 for no limit. Returns 0 on success or -1 for an unknown WAF or
+// a negative limit.
+//
+//export coraza_set_arg_limits
+func coraza_set_arg_limits(wafID C.uint64_t, maxArgs C.int, maxTotalLen C.int64_t) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || maxArgs < 0 || maxTotalLen < 0 {
+		return -1
+	}
+	if maxArgs == 0 && maxTotalLen == 0 {
+		ws.argLimits.Store(nil)
+		return 0
+	}
+	ws.argLimits.Store(&argLimits{maxArgs: int(maxArgs), maxTotalLen: int64(maxTotalLen)})
+	return 0
+}
+
+// limitQueryArgs returns uri with its query string cut at the argument
+// limits.
+func (st *txState) limitQueryArgs(uri string) string {
+	l := st.waf.argLimits.Load()
+	if l == nil {
+		return uri
+	}
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	st.args.open = false
+	if n := st.args.fit(l, []byte(query)); n < len(query) {
+		st.truncateArgs()
+		return path + "?" + query[:n]
+	}
+	return uri
+}
+
+// limitBodyArgs returns the part of a URL-encoded request body chunk within
+// the argument limits. Other bodies are returned unchanged.
+func (st *txState) limitBodyArgs(buf []byte) []byte {
+	l := st.waf.argLimits.Load()
+	if l == nil || !st.isFormBody() {
+		return buf
+	}
+	if st.args.truncated {
+		return nil
+	}
+	if n := st.args.fit(l, buf); n < len(buf) {
+		st.truncateArgs()
+		return buf[:n]
+	}
+	return buf
+}
+
+// limitJSONArgs returns the leading arguments passed to
+// coraza_process_request_body_json that stay within the argument limits,
+// counting each as key=value.
+func (st *txState) limitJSONArgs(args [][2]string) [][2]string {
+	l := st.waf.argLimits.Load()
+	if l == nil {
+		return args
+	}
+	if st.args.truncated {
+		return nil
+	}
+	for i, arg := range args {
+		n := int64(len(arg[0]) + 1 + len(arg[1]))
+		if (l.maxArgs > 0 && st.args.count >= l.maxArgs) || (l.maxTotalLen > 0 && st.args.length+n > l.maxTotalLen) {
+			st.truncateArgs()
+			return args[:i]
+		}
+		st.args.count++
+		st.args.length += n
+	}
+	return args
+}
+
+func (st *txState) isFormBody() bool {
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return false
+	}
+	for _, ct := range vars.RequestHeaders().Get("content-type") {
+		mediaType, _, _ := strings.Cut(ct, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded") {
+			return true
+		}
+	}
+	return false
+}
+
+// fit counts the leading bytes of data that stay within l and returns how
+// many there are.
+func (c *argCounter) fit(l *argLimits, data []byte) int {
+	for i, b := range data {
+		count := c.count
+		if !c.open || b == '&' {
+			count++
+		}
+		if (l.maxArgs > 0 && count > l.maxArgs) || (l.maxTotalLen > 0 && c.length >= l.maxTotalLen) {
+			return i
+		}
+		c.count, c.open = count, true
+		c.length++
+	}
+	return len(data)
+}
+
+// truncateArgs flags the request as over the argument limits and interrupts
+// it; Coraza ignores the interruption unless the rule engine is on.
+func (st *txState) truncateArgs() {
+	if st.args.truncated {
+		return
+	}
+	st.args.truncated = true
+	if vars, ok := txVariables(st.tx); ok {
+		vars.TX().Set(argsTruncatedVar, []string{"1"})
+	}
+	if ts, ok := st.tx.(plugintypes.TransactionState); ok {
+		ts.Interrupt(&types.Interruption{Status: 400, Action: "deny"})
+	}
+}
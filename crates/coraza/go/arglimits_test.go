@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestArgLimits floods each argument source past the limits and checks the
+// status, the arguments that reached Coraza and TX:args_truncated.
+func TestArgLimits(t *testing.T) {
+	query := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				b.WriteByte('&')
+			}
+			fmt.Fprintf(&b, "a%d=%d", i, i)
+		}
+		return b.String()
+	}
+	jsonArgs := func(n int) string {
+		fields := map[string]string{}
+		for i := 0; i < n; i++ {
+			fields[fmt.Sprintf("json.a%05d", i)] = "v"
+		}
+		b, _ := json.Marshal(fields)
+		return string(b)
+	}
+	form := func(tx uint64, body string) int64 {
+		requestHeaders(tx)
+		b := []byte(body)
+		return callInt(coraza_process_request_body, tx, unsafe.Pointer(&b[0]), len(b))
+	}
+
+	tests := []struct {
+		name        string
+		engine      string
+		maxArgs     int
+		maxTotalLen int64
+		run         func(tx uint64) int64
+		wantStatus  int64
+		// wantArgs is the number of ARGS that reached Coraza.
+		wantArgs  int64
+		truncated bool
+	}{
+		{"query flood", "On", 100, 0, func(tx uint64) int64 {
+			return callInt(coraza_process_request_headers, tx, "GET", "/?"+query(100000), "HTTP/1.1", "[]")
+		}, 400, 100, true},
+		{"query within limits", "On", 100, 0, func(tx uint64) int64 {
+			return callInt(coraza_process_request_headers, tx, "GET", "/?"+query(100), "HTTP/1.1", "[]")
+		}, 0, 100, false},
+		{"query total length", "On", 0, 11, func(tx uint64) int64 {
+			// "a0=0&a1=1&a2=2" is cut after "a0=0&a1=1&a".
+			return callInt(coraza_process_request_headers, tx, "GET", "/?"+query(3), "HTTP/1.1", "[]")
+		}, 400, 3, true},
+		{"form body flood", "On", 10, 0, func(tx uint64) int64 {
+			// Rejected while written, so the body is never parsed.
+			return form(tx, query(10000))
+		}, 400, 0, true},
+		{"JSON args flood", "On", 10, 0, func(tx uint64) int64 {
+			requestHeaders(tx)
+			return callInt(coraza_process_request_body_json, tx, jsonArgs(1000))
+		}, 400, 10, true},
+		{"JSON args within limits", "On", 10, 0, func(tx uint64) int64 {
+			requestHeaders(tx)
+			return callInt(coraza_process_request_body_json, tx, jsonArgs(10))
+		}, 0, 10, false},
+		{"JSON args total length", "On", 0, 25, func(tx uint64) int64 {
+			// Each argument counts as "json.a00000=v", 13 bytes.
+			requestHeaders(tx)
+			return callInt(coraza_process_request_body_json, tx, jsonArgs(3))
+		}, 400, 1, true},
+		{"detection only", "DetectionOnly", 10, 0, func(tx uint64) int64 {
+			return form(tx, query(10000))
+		}, 0, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := newTestWAF(t, "SecRuleEngine "+tt.engine+"\nSecRequestBodyAccess On")
+			if got := callInt(coraza_set_arg_limits, waf, tt.maxArgs, tt.maxTotalLen); got != 0 {
+				t.Fatalf("coraza_set_arg_limits = %d", got)
+			}
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+
+			if got := tt.run(tx); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if got := callInt(coraza_get_args_count, tx, argsAll); got != tt.wantArgs {
+				t.Errorf("ARGS count = %d, want %d", got, tt.wantArgs)
+			}
+			if got := collectionValue(t, tx, "TX", argsTruncatedVar) == "1"; got != tt.truncated {
+				t.Errorf("TX:%s set = %v, want %v", argsTruncatedVar, got, tt.truncated)
+			}
+		})
+	}
+}
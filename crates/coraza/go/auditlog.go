@@ -0,0 +1,387 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// Audit log serializations accepted by coraza_set_audit_log_format.
+const (
+	// auditFormatNative is Coraza's own JSON audit log format.
+	auditFormatNative = "native"
+	// auditFormatFlat is a single-level JSON object, one line per record.
+	auditFormatFlat = "flat"
+	// auditFormatOCSF loosely follows the OCSF HTTP Activity class.
+	auditFormatOCSF = "ocsf"
+)
+
+// coraza_set_audit_log_format selects how coraza_audit_log_json serializes
+// audit records for transactions of this WAF: "native" (the default), "flat"
+// or "ocsf". Returns 0 on success or -1 for an unknown WAF or format name.
+//
+//export coraza_set_audit_log_format
+func coraza_set_audit_log_format(wafID C.uint64_t, format *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	f := C.GoString(format)
+	switch f {
+	case auditFormatNative, auditFormatFlat, auditFormatOCSF:
+		ws.auditFormat.Store(f)
+		return 0
+	}
+	setLastError(fmt.Errorf("unknown audit log format %q", f))
+	return -1
+}
+
+// coraza_set_tx_context attaches a key/value pair of host metadata, such as
+// a trace ID, tenant or route, to the transaction. The pairs are included
+// in coraza_audit_log_json under "context" and play no part in rule
+// evaluation. Setting an existing key replaces its value and a NULL value
+// removes it. Returns 0 on success or -1 for an unknown handle or an empty
+// key.
+//
+//export coraza_set_tx_context
+func coraza_set_tx_context(txID C.uint64_t, key, value *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	k := C.GoString(key)
+	if k == "" {
+		setLastError(fmt.Errorf("empty context key"))
+		return -1
+	}
+
+	if value == nil {
+		delete(st.context, k)
+		return 0
+	}
+	if st.context == nil {
+		st.context = map[string]string{}
+	}
+	st.context[k] = C.GoString(value)
+	return 0
+}
+
+// coraza_audit_log_json returns the audit record of the transaction, in the
+// format configured on its WAF, or nil for an unknown handle. The record holds
+// the parts selected by SecAuditLogParts, plus the WAF's label if it has one
+// and the context set with coraza_set_tx_context, with header values masked
+// as set by coraza_set_masked_headers. The caller must free the returned
+// string.
+//
+//export coraza_audit_log_json
+func coraza_audit_log_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	al, ok := auditLog(st.tx)
+	if !ok {
+		return nil
+	}
+
+	label := st.waf.label
+	switch st.waf.auditFormat.Load().(string) {
+	case auditFormatFlat:
+		out := st.flatAuditLog(al)
+		if label != "" {
+			out["waf_label"] = label
+		}
+		if len(st.context) > 0 {
+			out["context"] = st.context
+		}
+		return jsonCString(out)
+	case auditFormatOCSF:
+		out := st.ocsfAuditLog(al)
+		if label != "" {
+			out["metadata"].(map[string]any)["labels"] = []string{label}
+		}
+		if len(st.context) > 0 {
+			out["unmapped"].(map[string]any)["context"] = st.context
+		}
+		return jsonCString(out)
+	}
+
+	extra := map[string]any{}
+	if label != "" {
+		extra["waf_label"] = label
+	}
+	if len(st.context) > 0 {
+		extra["context"] = st.context
+	}
+	return jsonCString(extendedAuditLog{AuditLog: al, st: st, extra: extra})
+}
+
+// extendedAuditLog adds top-level fields to Coraza's native audit record and
+// masks its headers and messages as configured on the WAF of st.
+type extendedAuditLog struct {
+	plugintypes.AuditLog
+	st    *txState
+	extra map[string]any
+}
+
+func (l extendedAuditLog) MarshalJSON() ([]byte, error) {
+	native, err := json.Marshal(l.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(native, &fields); err != nil {
+		return nil, err
+	}
+	if err := l.st.waf.maskAuditHeaders(fields); err != nil {
+		return nil, err
+	}
+	if err := l.st.maskAuditMessages(fields); err != nil {
+		return nil, err
+	}
+	for k, v := range l.extra {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		fields[k] = raw
+	}
+	return json.Marshal(fields)
+}
+
+// coraza_will_audit reports whether the logging phase run by coraza_finalize
+// would write an audit record for the transaction as it stands, following
+// Coraza's policy: 1 if SecAuditEngine is On, or RelevantOnly and a matched
+// rule marked the transaction for logging and its status (the interruption
+// status, else the response status) matches SecAuditLogRelevantStatus; 0
+// otherwise. Logging phase rules can still change the outcome, e.g. with
+// ctl:auditEngine. Returns -1 for an unknown handle.
+//
+//export coraza_will_audit
+func coraza_will_audit(txID C.uint64_t) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	v := reflect.ValueOf(tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	engine := v.Elem().FieldByName("AuditEngine")
+	if !engine.IsValid() || !engine.CanInt() {
+		return 0
+	}
+	switch types.AuditEngineStatus(engine.Int()) {
+	case types.AuditEngineOn:
+		return 1
+	case types.AuditEngineRelevantOnly:
+	default:
+		return 0
+	}
+
+	if marked := v.Elem().FieldByName("audit"); !marked.IsValid() || marked.Kind() != reflect.Bool || !marked.Bool() {
+		return 0
+	}
+	status := ""
+	if vars, ok := txVariables(tx); ok {
+		status = vars.ResponseStatus().Get()
+	}
+	if it := tx.Interruption(); it != nil {
+		status = strconv.Itoa(it.Status)
+	}
+	if waf, ok := internalWAF(tx); ok {
+		if re, ok := waf.Elem().FieldByName("AuditLogRelevantStatus").Interface().(*regexp.Regexp); ok && re != nil && !re.MatchString(status) {
+			return 0
+		}
+	}
+	return 1
+}
+
+// auditLog builds the audit record of a transaction. Coraza returns it from a
+// method whose result type is internal, so it is called through reflection.
+func auditLog(tx types.Transaction) (plugintypes.AuditLog, bool) {
+	m := reflect.ValueOf(tx).MethodByName("AuditLog")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil, false
+	}
+	al, ok := m.Call(nil)[0].Interface().(plugintypes.AuditLog)
+	return al, ok && al != nil
+}
+
+// flatAuditLog builds the flat audit record of the transaction from al.
+func (st *txState) flatAuditLog(al plugintypes.AuditLog) map[string]any {
+	t := al.Transaction()
+	out := map[string]any{
+		"timestamp":   t.Timestamp(),
+		"unix_nanos":  t.UnixTimestamp(),
+		"id":          t.ID(),
+		"client_ip":   t.ClientIP(),
+		"client_port": t.ClientPort(),
+		"host_ip":     t.HostIP(),
+		"host_port":   t.HostPort(),
+		"server_id":   t.ServerID(),
+	}
+	if t.HasRequest() {
+		req := t.Request()
+		out["request_method"] = req.Method()
+		out["request_uri"] = req.URI()
+		out["request_protocol"] = req.Protocol()
+	}
+	if t.HasResponse() {
+		out["response_status"] = t.Response().Status()
+	}
+
+	ruleIDs := []int{}
+	messages := []string{}
+	severity := types.RuleSeverity(-1)
+	severities := st.ruleSeverities()
+	for _, m := range al.Messages() {
+		d := m.Data()
+		ruleIDs = append(ruleIDs, d.ID())
+		messages = append(messages, st.maskMessage(m.Message()))
+		if s, ok := severities[d.ID()]; ok && (severity < 0 || s < severity) {
+			severity = s
+		}
+	}
+	out["rule_ids"] = ruleIDs
+	out["messages"] = messages
+	if severity >= 0 {
+		out["severity"] = severity.String()
+	}
+	return out
+}
+
+// ocsfAuditLog builds the OCSF audit record of the transaction from al.
+func (st *txState) ocsfAuditLog(al plugintypes.AuditLog) map[string]any {
+	t := al.Transaction()
+	out := map[string]any{
+		"category_uid": 4,    // Network Activity
+		"class_uid":    4002, // HTTP Activity
+		"activity_id":  99,   // Other
+		"time":         time.Unix(0, t.UnixTimestamp()).UnixMilli(),
+		"metadata": map[string]any{
+			"uid":     t.ID(),
+			"product": map[string]any{"name": "Coraza", "vendor_name": "OWASP"},
+		},
+		"src_endpoint": map[string]any{"ip": t.ClientIP(), "port": t.ClientPort()},
+		"dst_endpoint": map[string]any{"ip": t.HostIP(), "port": t.HostPort(), "hostname": t.ServerID()},
+	}
+	if t.HasRequest() {
+		req := t.Request()
+		out["http_request"] = map[string]any{
+			"http_method": req.Method(),
+			"url":         map[string]any{"url_string": req.URI()},
+			"version":     req.Protocol(),
+		}
+	}
+	if t.HasResponse() {
+		out["http_response"] = map[string]any{"code": t.Response().Status()}
+	}
+
+	findings := []map[string]any{}
+	severity := types.RuleSeverity(-1)
+	severities := st.ruleSeverities()
+	for _, m := range al.Messages() {
+		d := m.Data()
+		finding := map[string]any{
+			"uid":   d.ID(),
+			"title": st.maskMessage(m.Message()),
+			"data":  st.maskMessage(d.Data()),
+			"tags":  d.Tags(),
+		}
+		if s, ok := severities[d.ID()]; ok {
+			finding["severity"] = s.String()
+			if severity < 0 || s < severity {
+				severity = s
+			}
+		}
+		findings = append(findings, finding)
+	}
+	out["unmapped"] = map[string]any{"rules": findings}
+	out["severity_id"] = ocsfSeverity(severity)
+	return out
+}
+
+// ruleSeverities returns the severities of the transaction's matched rules
+// that have a severity action, by rule ID.
+func (st *txState) ruleSeverities() map[int]types.RuleSeverity {
+	severities := map[int]types.RuleSeverity{}
+	for _, mr := range st.tx.MatchedRules() {
+		if s, ok := ruleSeverity(mr.Rule()); ok {
+			severities[mr.Rule().ID()] = s
+		}
+	}
+	return severities
+}
+
+// ocsfSeverity maps a syslog-style rule severity, where lower values are more
+// severe, onto OCSF's severity_id. A negative severity means nothing matched.
+func ocsfSeverity(s types.RuleSeverity) int {
+	switch {
+	case s < 0:
+		return 1 // Informational
+	case s <= types.RuleSeverityCritical:
+		return 5 // Critical
+	case s == types.RuleSeverityError:
+		return 4 // High
+	case s == types.RuleSeverityWarning:
+		return 3 // Medium
+	case s == types.RuleSeverityNotice:
+		return 2 // Low
+	}
+	return 1 // Informational
+}
+
+// coraza_finalize runs the logging phase, writing the audit record, then
+// stores 1 in *blockedOut if the transaction was interrupted and 0 otherwise.
+// It returns the final interruption status, 0 if the transaction was not
+// interrupted, or -1 for an unknown handle. The logging phase only runs once;
+// calling this again just reports the verdict. blockedOut may be nil.
+//
+//export coraza_finalize
+func coraza_finalize(txID C.uint64_t, blockedOut *C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+
+	if !st.logged {
+		start := time.Now()
+		status := st.guard(func() C.int {
+			defer st.complete(types.PhaseLogging, start)
+			st.tx.ProcessLogging()
+			st.releaseCapture()
+			return 0
+		})
+		if status == processingTimedOut {
+			return status
+		}
+		st.logged = true
+	}
+
+	it := st.tx.Interruption()
+	blocked := it != nil && !st.waf.passThrough()
+	if blockedOut != nil {
+		*blockedOut = 0
+		if blocked {
+			*blockedOut = 1
+		}
+	}
+	if !blocked {
+		return 0
+	}
+	return C.int(st.status(it))
+}
@@ -0,0 +1,45 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "unsafe"
+
+// coraza_new_transactions creates n transactions on the given WAF in a single
+// call, writing their IDs into out (which must have room for n entries).
+// Returns n, or -1 for an unknown WAF, a non-positive n or a NULL out, in
+// which case out is left untouched.
+//
+//export coraza_new_transactions
+func coraza_new_transactions(wafID C.uint64_t, n C.int, out *C.uint64_t) C.int {
+	if n <= 0 || out == nil {
+		return -1
+	}
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	ids := unsafe.Slice(out, int(n))
+	for i := range ids {
+		ids[i] = C.uint64_t(newTransaction(ws))
+	}
+	return n
+}
+
+// coraza_free_transactions frees the n transactions whose IDs are in ids, as
+// coraza_free_transaction does for each. Unknown IDs, and repeats of an ID
+// already freed, are ignored.
+//
+//export coraza_free_transactions
+func coraza_free_transactions(ids *C.uint64_t, n C.int) {
+	if n <= 0 || ids == nil {
+		return
+	}
+	for _, id := range unsafe.Slice(ids, int(n)) {
+		coraza_free_transaction(id)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// defaultBlockPage is rendered by coraza_get_block_page_html unless the WAF
+// has its own template.
+var defaultBlockPage = template.Must(template.New("block").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Status}} Request blocked</title></head>
+<body>
+<h1>Request blocked</h1>
+<p>Your request was blocked by the web application firewall.</p>
+<p>If you believe this is an error, contact support with the reference below.</p>
+<ul>
+<li>Status: {{.Status}}</li>
+<li>Rule: {{.RuleID}}</li>
+<li>Reference: {{.TransactionID}}</li>
+</ul>
+</body>
+</html>
+`))
+
+// blockPageData is the data passed to block page templates.
+type blockPageData struct {
+	Status        int
+	RuleID        int
+	TransactionID string
+}
+
+// coraza_set_block_page_template replaces the block page rendered for
+// transactions of this WAF. The template uses Go html/template syntax and may
+// reference {{.Status}}, {{.RuleID}} and {{.TransactionID}}. Returns 0 on
+// success or -1 for an unknown WAF or a template that fails to parse.
+//
+//export coraza_set_block_page_template
+func coraza_set_block_page_template(wafID C.uint64_t, tmpl *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	t, err := template.New("block").Parse(C.GoString(tmpl))
+	if err != nil {
+		setLastError(err)
+		return -1
+	}
+	ws.blockPage.Store(t)
+	return 0
+}
+
+// coraza_get_block_page_html renders the block page for an interrupted
+// transaction, or returns nil if the transaction was not interrupted, the WAF
+// is a dry run or its enforcement is off. The caller must free the returned
+// string.
+//
+//export coraza_get_block_page_html
+func coraza_get_block_page_html(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	it := st.enforcedInterruption()
+	if it == nil {
+		return nil
+	}
+
+	page, err := st.blockPage(it, st.status(it))
+	if err != nil {
+		setLastError(err)
+		return nil
+	}
+	return C.CString(page)
+}
+
+// blockPage renders the WAF's block page for the interruption, sent with
+// the given status.
+func (st *txState) blockPage(it *types.Interruption, status int) (string, error) {
+	t := defaultBlockPage
+	if custom, ok := st.waf.blockPage.Load().(*template.Template); ok {
+		t = custom
+	}
+	var b strings.Builder
+	err := t.Execute(&b, blockPageData{
+		Status:        status,
+		RuleID:        it.RuleID,
+		TransactionID: st.tx.ID(),
+	})
+	return b.String(), err
+}
+
+// blockResponse is the document returned by coraza_get_block_response_json.
+type blockResponse struct {
+	Action          string      `json:"action"`
+	Status          int         `json:"status"`
+	Headers         [][2]string `json:"headers"`
+	Body            string      `json:"body"`
+	CloseConnection bool        `json:"close_connection"`
+}
+
+// coraza_get_block_response_json returns the response to send for an
+// interrupted transaction, as a JSON object with the "action", the "status",
+// the "headers" as [name, value] pairs, the "body" and "close_connection":
+//
+//   - deny (and any other action): the interruption's status, 403 if the
+//     rule set none, with the block page (see coraza_set_block_page_template)
+//     as an HTML body.
+//   - redirect: the redirect status (see coraza_redirect_json) with a
+//     Location header and no body.
+//   - drop: "close_connection" is true and the host should close the
+//     connection without responding; the other fields are informational.
+//
+// Returns nil if the transaction was not interrupted, the WAF is a dry run or
+// its enforcement is off, or for an unknown handle. The caller must free the
+// returned string.
+//
+//export coraza_get_block_response_json
+func coraza_get_block_response_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	it := st.enforcedInterruption()
+	if it == nil {
+		return nil
+	}
+
+	resp := blockResponse{Action: it.Action, Headers: [][2]string{}}
+	switch it.Action {
+	case "redirect":
+		resp.Status = redirectStatus(it.Status)
+		resp.Headers = append(resp.Headers, [2]string{"Location", it.Data})
+	case "drop":
+		resp.Status = st.status(it)
+		resp.CloseConnection = true
+	default:
+		resp.Status = st.status(it)
+		if resp.Status == 0 {
+			resp.Status = http.StatusForbidden
+		}
+		page, err := st.blockPage(it, resp.Status)
+		if err != nil {
+			setLastError(err)
+			return nil
+		}
+		resp.Headers = append(resp.Headers, [2]string{"Content-Type", "text/html; charset=utf-8"})
+		resp.Body = page
+	}
+	return jsonCString(resp)
+}
@@ -0,0 +1,111 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// blockStatuses overrides the status of deny interruptions. It is replaced
+// as a whole on every change, so readers never need a lock.
+type blockStatuses struct {
+	// defaultStatus replaces the status of every deny not matched by tag;
+	// 0 keeps the rule's own status.
+	defaultStatus int
+	// byTag maps a rule tag to the status used when the denying rule has it.
+	byTag map[string]int
+}
+
+// coraza_set_block_status sets the status reported for deny interruptions of
+// this WAF in place of the rule's own status: value. Coraza reports 0 for a
+// deny without one, so this also gives such rules a blocking status. Pass 0 to
+// report the rule's status again. Redirects and other actions are not
+// affected, nor are the bridge's own interruptions (rule 0), such as 405 for
+// a method outside coraza_set_allowed_methods. Returns 0 on success or -1 for an unknown WAF or a status
+// outside 100-599.
+//
+//export coraza_set_block_status
+func coraza_set_block_status(wafID C.uint64_t, defaultStatus C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || (defaultStatus != 0 && !validStatus(defaultStatus)) {
+		return -1
+	}
+	ws.updateBlockStatuses(func(b *blockStatuses) {
+		b.defaultStatus = int(defaultStatus)
+	})
+	return 0
+}
+
+// coraza_set_block_status_for_tag reports status for deny interruptions whose
+// rule carries tag, e.g. 429 for "attack-dos". Tag overrides take precedence
+// over coraza_set_block_status; if the rule has several mapped tags, the first
+// one in rule order wins. Pass 0 to remove the mapping. Returns 0 on success
+// or -1 for an unknown WAF or a status outside 100-599.
+//
+//export coraza_set_block_status_for_tag
+func coraza_set_block_status_for_tag(wafID C.uint64_t, tag *C.char, status C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || (status != 0 && !validStatus(status)) {
+		return -1
+	}
+	t := C.GoString(tag)
+	ws.updateBlockStatuses(func(b *blockStatuses) {
+		if status == 0 {
+			delete(b.byTag, t)
+		} else {
+			b.byTag[t] = int(status)
+		}
+	})
+	return 0
+}
+
+func validStatus(status C.int) bool {
+	return status >= 100 && status <= 599
+}
+
+// updateBlockStatuses applies fn to a copy of the current overrides and
+// publishes the result.
+func (ws *wafState) updateBlockStatuses(fn func(*blockStatuses)) {
+	ws.blockStatusMu.Lock()
+	defer ws.blockStatusMu.Unlock()
+
+	next := &blockStatuses{byTag: make(map[string]int)}
+	if cur := ws.blockStatus.Load(); cur != nil {
+		next.defaultStatus = cur.defaultStatus
+		for k, v := range cur.byTag {
+			next.byTag[k] = v
+		}
+	}
+	fn(next)
+	ws.blockStatus.Store(next)
+}
+
+// status returns the status the bridge reports for an interruption, after
+// applying the WAF's block status overrides to those raised by rules.
+func (st *txState) status(it *types.Interruption) int {
+	b := st.waf.blockStatus.Load()
+	if b == nil || it.Action != "deny" || it.RuleID == 0 {
+		return it.Status
+	}
+	if len(b.byTag) > 0 {
+		for _, mr := range st.tx.MatchedRules() {
+			if mr.Rule().ID() != it.RuleID {
+				continue
+			}
+			for _, tag := range mr.Rule().Tags() {
+				if s, ok := b.byTag[tag]; ok {
+					return s
+				}
+			}
+			break
+		}
+	}
+	if b.defaultStatus != 0 {
+		return b.defaultStatus
+	}
+	return it.Status
+}
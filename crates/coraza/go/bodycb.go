@@ -0,0 +1,50 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+typedef void (*coraza_body_interruption_cb)(uint64_t tx_id, int status);
+
+static inline void coraza_call_body_interruption_cb(coraza_body_interruption_cb cb, uint64_t tx_id, int status) {
+	cb(tx_id, status);
+}
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// bodyInterruptionCallback is the C function notified of interruptions
+// during body writes, or nil.
+var bodyInterruptionCallback atomic.Pointer[byte]
+
+// coraza_set_body_interruption_callback registers cb to be called with the
+// transaction ID and interruption status when a chunk passed to
+// coraza_write_request_body or coraza_write_response_body trips a body limit
+// or a rule. It is called once per interruption, on the writing thread,
+// before the write returns the same status, so a streaming loop can react in
+// the callback rather than check every write. The callback applies to all
+// WAFs and must not free the transaction. Pass NULL to unregister.
+//
+//export coraza_set_body_interruption_callback
+func coraza_set_body_interruption_callback(cb C.coraza_body_interruption_cb) {
+	bodyInterruptionCallback.Store((*byte)(unsafe.Pointer(cb)))
+}
+
+// notifyBodyInterruption reports status to the body interruption callback
+// if the write that returned it is the first to interrupt the transaction;
+// prev is the interruption seen before the write.
+func (st *txState) notifyBodyInterruption(prev *types.Interruption, status C.int) C.int {
+	if status <= 0 || prev != nil {
+		return status
+	}
+	if cb := bodyInterruptionCallback.Load(); cb != nil {
+		C.coraza_call_body_interruption_cb(C.coraza_body_interruption_cb(unsafe.Pointer(cb)), C.uint64_t(st.txID), status)
+	}
+	return status
+}
@@ -0,0 +1,66 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// coraza_request_body_bytes copies the request body as Coraza stored it, which
+// never exceeds the request body limit, into out. It returns the number of
+// bytes copied, or -1 for an unknown handle or, setting the last error, if
+// the body cannot be read. If the body is larger than maxLen nothing is
+// copied and the required size is returned instead.
+//
+//export coraza_request_body_bytes
+func coraza_request_body_bytes(txID C.uint64_t, out unsafe.Pointer, maxLen C.int) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	r, err := tx.RequestBodyReader()
+	if err != nil {
+		setLastError(fmt.Errorf("reading request body: %w", err))
+		return -1
+	}
+	return copyBody(r, requestBodyLimit(tx), out, maxLen)
+}
+
+// coraza_response_body_bytes is coraza_request_body_bytes for the response
+// body, bounded by the response body limit.
+//
+//export coraza_response_body_bytes
+func coraza_response_body_bytes(txID C.uint64_t, out unsafe.Pointer, maxLen C.int) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	r, err := tx.ResponseBodyReader()
+	if err != nil {
+		setLastError(fmt.Errorf("reading response body: %w", err))
+		return -1
+	}
+	return copyBody(r, responseBodyLimit(tx), out, maxLen)
+}
+
+// copyBody reads up to limit bytes from r into out as described for
+// coraza_request_body_bytes. Read errors are reported as -1: a status from
+// inspectionError could not be told apart from a byte count.
+func copyBody(r io.Reader, limit int64, out unsafe.Pointer, maxLen C.int) C.int {
+	body, err := io.ReadAll(io.LimitReader(r, limit))
+	if err != nil {
+		setLastError(fmt.Errorf("reading body: %w", err))
+		return -1
+	}
+	if len(body) > int(maxLen) || out == nil {
+		return C.int(len(body))
+	}
+	copy(unsafe.Slice((*byte)(out), len(body)), body)
+	return C.int(len(body))
+}
@@ -0,0 +1,195 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_set_tmp_dir sets the directory request bodies spill to once they
+// exceed the in-memory limit (see coraza_new_waf_with_options), in place of
+// the system temporary directory. Coraza keeps spent transactions for reuse
+// along with their buffers, so call this right after creating the WAF:
+// transactions reused from before may keep the previous directory. Returns 0
+// on success or -1, setting the last error, for an unknown WAF or a path that
+// is not a writable directory.
+//
+//export coraza_set_tmp_dir
+func coraza_set_tmp_dir(wafID C.uint64_t, path *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	dir := C.GoString(path)
+	f, err := os.CreateTemp(dir, "coraza-tmp-check*")
+	if err != nil {
+		setLastError(fmt.Errorf("tmp dir is not writable: %w", err))
+		return -1
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	if !setTmpDir(ws.engine(), dir) {
+		return -1
+	}
+	ws.tmpDir = dir
+	return 0
+}
+
+// setTmpDir sets the WAF's TmpDir, which is only reachable through a
+// transaction.
+func setTmpDir(waf coraza.WAF, dir string) bool {
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	w, ok := internalWAF(tx)
+	if !ok {
+		return false
+	}
+	tmpDir := w.Elem().FieldByName("TmpDir")
+	if !tmpDir.CanSet() || tmpDir.Kind() != reflect.String {
+		return false
+	}
+	tmpDir.SetString(dir)
+	for _, buffer := range []string{"requestBodyBuffer", "responseBodyBuffer"} {
+		if opt, ok := bodyBufferOption(tx, buffer, "TmpPath", reflect.String); ok {
+			opt.SetString(dir)
+		}
+	}
+	return true
+}
+
+// coraza_set_request_body_limit overrides the request body limit for a single
+// transaction, e.g. to accept a large upload on a trusted endpoint. Bodies
+// past the limit are rejected or truncated according to
+// SecRequestBodyLimitAction, whether written in one call or in chunks. It must
+// be called before any request body is written. Returns 0 on success or -1 for
+// an unknown handle, a non-positive limit or a body already in progress.
+//
+//export coraza_set_request_body_limit
+func coraza_set_request_body_limit(txID C.uint64_t, limit C.int64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || limit <= 0 || st.requestBodyStarted {
+		return -1
+	}
+	if st.wafRequestBodyLimit == 0 {
+		st.wafRequestBodyLimit = requestBodyLimit(st.tx)
+	}
+	if !setRequestBodyLimit(st.tx, int64(limit)) {
+		return -1
+	}
+	return 0
+}
+
+// coraza_set_response_body_limit is coraza_set_request_body_limit for the
+// response body, e.g. to inspect a large download from a trusted upstream.
+// Bodies past the limit are rejected or truncated according to
+// SecResponseBodyLimitAction, whether written in one call, in chunks or as an
+// event stream. It must be called before any response body is written.
+// Returns 0 on success or -1 for an unknown handle, a non-positive limit or a
+// body already in progress.
+//
+//export coraza_set_response_body_limit
+func coraza_set_response_body_limit(txID C.uint64_t, limit C.int64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || limit <= 0 || st.responseBodyStarted {
+		return -1
+	}
+	if st.wafResponseBodyLimit == 0 {
+		st.wafResponseBodyLimit = responseBodyLimit(st.tx)
+	}
+	if !setResponseBodyLimit(st.tx, int64(limit)) {
+		return -1
+	}
+	return 0
+}
+
+// requestBodyLimit and responseBodyLimit return the transaction's body
+// limits. Coraza exports the fields on its transaction type but not on the
+// public interface.
+func requestBodyLimit(tx types.Transaction) int64 {
+	return txInt64Field(tx, "RequestBodyLimit", defaultRequestBodyLimit)
+}
+
+func responseBodyLimit(tx types.Transaction) int64 {
+	return txInt64Field(tx, "ResponseBodyLimit", defaultResponseBodyLimit)
+}
+
+// setRequestBodyLimit and setResponseBodyLimit change the transaction's body
+// limits. Coraza copies the WAF limit into both the transaction and its body
+// buffer, which refuses writes past its own copy, so both are updated.
+func setRequestBodyLimit(tx types.Transaction, limit int64) bool {
+	return setBodyLimit(tx, "RequestBodyLimit", "requestBodyBuffer", limit)
+}
+
+func setResponseBodyLimit(tx types.Transaction, limit int64) bool {
+	return setBodyLimit(tx, "ResponseBodyLimit", "responseBodyBuffer", limit)
+}
+
+func setBodyLimit(tx types.Transaction, name, buffer string, limit int64) bool {
+	v := reflect.ValueOf(tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	// Both fields are checked before either is written, so a Coraza release
+	// that changes them leaves the transaction untouched.
+	field := v.Elem().FieldByName(name)
+	bufLimit, ok := bodyBufferOption(tx, buffer, "Limit", reflect.Int64)
+	if !ok || !field.CanSet() || field.Kind() != reflect.Int64 {
+		return false
+	}
+	bufLimit.SetInt(limit)
+	field.SetInt(limit)
+	return true
+}
+
+// bodyBufferOptionsType is the type Coraza's body buffers keep their options
+// in, checked before writing to them.
+var bodyBufferOptionsType = reflect.TypeOf(types.BodyBufferOptions{})
+
+// bodyBufferOption returns a settable option of one of the transaction's
+// body buffers, which Coraza keeps in unexported fields. It reports false
+// unless the buffer holds its options as types.BodyBufferOptions and the
+// option has the given kind.
+func bodyBufferOption(tx types.Transaction, buffer, option string, kind reflect.Kind) (reflect.Value, bool) {
+	v := reflect.ValueOf(tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	buf := v.Elem().FieldByName(buffer)
+	if !buf.IsValid() || buf.Kind() != reflect.Pointer || buf.IsNil() || buf.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	opts, ok := unexportedField(buf.Elem(), "options")
+	if !ok || opts.Type() != bodyBufferOptionsType {
+		return reflect.Value{}, false
+	}
+	opt := opts.FieldByName(option)
+	if !opt.IsValid() || opt.Kind() != kind {
+		return reflect.Value{}, false
+	}
+	return opt, true
+}
+
+func txInt64Field(tx types.Transaction, name string, def int64) int64 {
+	v := reflect.ValueOf(tx)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName(name); f.IsValid() && f.CanInt() {
+			return f.Int()
+		}
+	}
+	return def
+}
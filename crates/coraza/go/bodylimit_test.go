@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestBodyLimitLayout checks that Coraza's transaction still has the fields
+// the per-transaction body limits are written to. It fails when a Coraza
+// release renames them or changes their types.
+func TestBodyLimitLayout(t *testing.T) {
+	waf := newTestWAF(t, "SecRuleEngine On")
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	val, _ := txInstances.Load(tx)
+	ctx := val.(*txState).tx
+
+	for _, tt := range []struct {
+		field, buffer string
+		set           func(int64) bool
+		get           func() int64
+	}{
+		{"RequestBodyLimit", "requestBodyBuffer", func(n int64) bool { return setRequestBodyLimit(ctx, n) }, func() int64 { return requestBodyLimit(ctx) }},
+		{"ResponseBodyLimit", "responseBodyBuffer", func(n int64) bool { return setResponseBodyLimit(ctx, n) }, func() int64 { return responseBodyLimit(ctx) }},
+	} {
+		if f := reflect.ValueOf(ctx).Elem().FieldByName(tt.field); f.Kind() != reflect.Int64 {
+			t.Errorf("%s has kind %v, want int64", tt.field, f.Kind())
+		}
+		for option, kind := range map[string]reflect.Kind{"Limit": reflect.Int64, "TmpPath": reflect.String} {
+			if _, ok := bodyBufferOption(ctx, tt.buffer, option, kind); !ok {
+				t.Errorf("%s option %s not found", tt.buffer, option)
+			}
+		}
+		if !tt.set(1234) {
+			t.Fatalf("setting %s failed", tt.field)
+		}
+		limit, _ := bodyBufferOption(ctx, tt.buffer, "Limit", reflect.Int64)
+		if got := tt.get(); got != 1234 || limit.Int() != 1234 {
+			t.Errorf("%s = %d, buffer limit %d, want 1234", tt.field, got, limit.Int())
+		}
+	}
+
+	if _, ok := bodyBufferOption(ctx, "requestBodyBuffer", "Limit", reflect.String); ok {
+		t.Error("bodyBufferOption accepted the wrong kind")
+	}
+	if _, ok := bodyBufferOption(ctx, "missingBuffer", "Limit", reflect.Int64); ok {
+		t.Error("bodyBufferOption accepted a missing buffer")
+	}
+	if setBodyLimit(ctx, "MissingLimit", "requestBodyBuffer", 1) {
+		t.Error("setBodyLimit accepted a missing field")
+	}
+	limit, _ := bodyBufferOption(ctx, "requestBodyBuffer", "Limit", reflect.Int64)
+	if limit.Int() != 1234 {
+		t.Errorf("failed setBodyLimit changed the buffer limit to %d", limit.Int())
+	}
+}
+
+func TestSetRequestBodyLimit(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRequestBodyAccess On
+SecRequestBodyLimit 16
+SecRequestBodyLimitAction Reject`)
+	body := []byte("a=" + strings.Repeat("x", 30))
+
+	tests := []struct {
+		name    string
+		limit   int64
+		chunked bool
+		want    int64
+	}{
+		{"waf limit", 0, false, 413},
+		{"raised", 64, false, 0},
+		{"raised chunked", 64, true, 0},
+		{"lowered", 8, false, 413},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			if tt.limit != 0 {
+				if got := callInt(coraza_set_request_body_limit, tx, tt.limit); got != 0 {
+					t.Fatalf("coraza_set_request_body_limit = %d: %s", got, lastError)
+				}
+			}
+			requestHeaders(tx)
+			var got int64
+			if tt.chunked {
+				for i := 0; i < len(body) && got == 0; i += 8 {
+					chunk := body[i:min(i+8, len(body))]
+					got = callInt(coraza_write_request_body, tx, unsafe.Pointer(&chunk[0]), len(chunk))
+				}
+				if got == 0 {
+					got = callInt(coraza_process_request_body, tx, nil, 0)
+				}
+			} else {
+				got = callInt(coraza_process_request_body, tx, unsafe.Pointer(&body[0]), len(body))
+			}
+			if got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+			if got := callInt(coraza_set_request_body_limit, tx, 128); got != -1 {
+				t.Errorf("coraza_set_request_body_limit after the body = %d, want -1", got)
+			}
+		})
+	}
+
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	for _, limit := range []int64{0, -1} {
+		if got := callInt(coraza_set_request_body_limit, tx, limit); got != -1 {
+			t.Errorf("coraza_set_request_body_limit(%d) = %d, want -1", limit, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// coraza_set_capture_on_block makes transactions of this WAF keep a copy of
+// the request and response body bytes exactly as the host passed them, up to
+// the request and response body limits, so blocked transactions can be
+// logged. The copies of a transaction that is not interrupted are released
+// once its bodies can no longer be inspected: when the response body phase
+// finishes, including for a 101 response, or at the latest in the logging
+// phase. Returns 0 on success or -1 for an unknown WAF.
+//
+//export coraza_set_capture_on_block
+func coraza_set_capture_on_block(wafID C.uint64_t, enabled C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	ws.captureOnBlock.Store(enabled != 0)
+	return 0
+}
+
+// coraza_set_capture_request_body makes the transaction keep a copy of its
+// request body for forensic logging, whether or not it is interrupted and
+// even if SecRequestBodyAccess is off, so no body rules run; read it back
+// with coraza_get_full_request_body. The host must still pass the body to
+// coraza_write_request_body or coraza_process_request_body, and
+// coraza_should_read_request_body asks for it. The copy is capped at the
+// request body limit and held until the transaction is freed, so it costs up
+// to that limit in memory per transaction. It must be called before any
+// request body is written. Returns 0 on success or -1 for an unknown handle
+// or a body already in progress.
+//
+//export coraza_set_capture_request_body
+func coraza_set_capture_request_body(txID C.uint64_t, enabled C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || st.requestBodyStarted {
+		return -1
+	}
+	st.captureRequestBody = enabled != 0
+	return 0
+}
+
+// coraza_get_full_request_body copies the captured request body of an
+// interrupted transaction, or of one set to capture it with
+// coraza_set_capture_request_body, into out and returns the number of bytes
+// copied. It returns 0 when nothing was captured or the transaction was not
+// interrupted, and -1 for an unknown handle. If the body is larger than maxLen
+// nothing is copied and the required size is returned instead.
+//
+//export coraza_get_full_request_body
+func coraza_get_full_request_body(txID C.uint64_t, out unsafe.Pointer, maxLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	if st.tx.Interruption() == nil && !st.captureRequestBody {
+		return 0
+	}
+	return copyBody(bytes.NewReader(st.capturedRequest.Bytes()), int64(st.capturedRequest.Len()), out, maxLen)
+}
+
+// coraza_get_full_response_body is coraza_get_full_request_body for the
+// response body.
+//
+//export coraza_get_full_response_body
+func coraza_get_full_response_body(txID C.uint64_t, out unsafe.Pointer, maxLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	if st.tx.Interruption() == nil {
+		return 0
+	}
+	return copyBody(bytes.NewReader(st.capturedResponse.Bytes()), int64(st.capturedResponse.Len()), out, maxLen)
+}
+
+// captureRequest appends request body bytes to the captured request body
+// when the WAF captures bodies on block or the transaction captures it in any
+// case, up to the request body limit.
+func (st *txState) captureRequest(buf []byte) {
+	if st.captureRequestBody || st.waf.captureOnBlock.Load() {
+		appendCapped(&st.capturedRequest, buf, requestBodyLimit(st.tx))
+	}
+}
+
+// captureResponse appends response body bytes to the captured response body
+// when the WAF captures bodies on block, up to the response body limit.
+func (st *txState) captureResponse(buf []byte) {
+	if st.waf.captureOnBlock.Load() {
+		appendCapped(&st.capturedResponse, buf, responseBodyLimit(st.tx))
+	}
+}
+
+// appendCapped appends as much of buf to dst as fits in limit bytes.
+func appendCapped(dst *bytes.Buffer, buf []byte, limit int64) {
+	if room := limit - int64(dst.Len()); room > 0 {
+		dst.Write(buf[:min(int64(len(buf)), room)])
+	}
+}
+
+// releaseCapture drops the captured bodies once the transaction can no longer
+// be interrupted by body inspection. An interrupted transaction keeps them
+// until it is freed.
+func (st *txState) releaseCapture() {
+	if st.tx.Interruption() == nil {
+		if !st.captureRequestBody {
+			st.capturedRequest = bytes.Buffer{}
+		}
+		st.capturedResponse = bytes.Buffer{}
+	}
+}
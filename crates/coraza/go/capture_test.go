@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+// TestCaptureOnBlockBounded streams bodies far past the body limits into a
+// WAF that captures them on block, and checks the copies stop at the limits.
+func TestCaptureOnBlockBounded(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRequestBodyAccess On
+SecRequestBodyLimit 64
+SecRequestBodyLimitAction ProcessPartial
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/event-stream
+SecResponseBodyLimit 64
+SecResponseBodyLimitAction ProcessPartial
+SecRule REQUEST_BODY "@contains attack" "id:1,phase:2,deny,status:403"
+SecRule RESPONSE_BODY "@contains leak" "id:2,phase:4,deny,status:502"`)
+	callInt(coraza_set_capture_on_block, waf, 1)
+	chunk := bytes.Repeat([]byte("x"), 1024)
+	chunkPtr := unsafe.Pointer(&chunk[0])
+
+	t.Run("request", func(t *testing.T) {
+		tx := uint64(callInt(coraza_new_transaction, waf))
+		defer call(coraza_free_transaction, tx)
+		requestHeaders(tx)
+		attack := []byte("attack=1&")
+		callInt(coraza_write_request_body, tx, unsafe.Pointer(&attack[0]), len(attack))
+		for i := 0; i < 100; i++ {
+			callInt(coraza_write_request_body, tx, chunkPtr, len(chunk))
+		}
+		if got := callInt(coraza_process_request_body, tx, nil, 0); got != 403 {
+			t.Fatalf("status = %d, want 403", got)
+		}
+		if got := callInt(coraza_get_full_request_body, tx, nil, 0); got != 64 {
+			t.Errorf("captured %d request bytes, want the 64 byte limit", got)
+		}
+	})
+
+	t.Run("streamed response", func(t *testing.T) {
+		tx := uint64(callInt(coraza_new_transaction, waf))
+		defer call(coraza_free_transaction, tx)
+		request(tx)
+		callInt(coraza_process_response_headers, tx, 200, `[["Content-Type","text/event-stream"]]`)
+		callInt(coraza_set_response_streaming, tx, 1)
+		event := []byte("data: ok\n\n")
+		for i := 0; i < 100; i++ {
+			if got := callInt(coraza_write_response_body, tx, unsafe.Pointer(&event[0]), len(event)); got != 0 {
+				t.Fatalf("event %d: status = %d, want 0", i, got)
+			}
+		}
+		leak := []byte("data: leak\n\n")
+		if got := callInt(coraza_write_response_body, tx, unsafe.Pointer(&leak[0]), len(leak)); got != 502 {
+			t.Fatalf("status = %d, want 502", got)
+		}
+		if got := callInt(coraza_get_full_response_body, tx, nil, 0); got != 64 {
+			t.Errorf("captured %d response bytes, want the 64 byte limit", got)
+		}
+	})
+}
+
+// TestCaptureReleased checks the copies of a transaction that is not
+// interrupted are dropped on the paths that never inspect a response body,
+// while an interrupted one keeps them.
+func TestCaptureReleased(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRequestBodyAccess On
+SecRule REQUEST_BODY "@contains attack" "id:1,phase:2,deny,status:403"`)
+	callInt(coraza_set_capture_on_block, waf, 1)
+	tests := []struct {
+		name   string
+		body   string
+		finish func(tx uint64)
+		want   int
+	}{
+		{"switching protocols", "a=1", func(tx uint64) {
+			callInt(coraza_process_response_headers, tx, 101, "[]")
+		}, 0},
+		{"no response", "a=1", func(tx uint64) {
+			callInt(coraza_finalize, tx, nil)
+		}, 0},
+		{"interrupted", "attack=1", func(tx uint64) {
+			callInt(coraza_finalize, tx, nil)
+		}, len("attack=1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			requestHeaders(tx)
+			body := []byte(tt.body)
+			callInt(coraza_process_request_body, tx, unsafe.Pointer(&body[0]), len(body))
+			tt.finish(tx)
+			val, _ := txInstances.Load(tx)
+			if got := val.(*txState).capturedRequest.Len(); got != tt.want {
+				t.Errorf("captured request holds %d bytes, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// cefHeaderEscaper and cefExtensionEscaper escape CEF header fields and
+// extension values.
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// corazaVersion returns the version of the Coraza module linked in, for the
+// CEF Device Version field.
+var corazaVersion = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/corazawaf/coraza/v3" {
+				return strings.TrimPrefix(dep.Version, "v")
+			}
+		}
+	}
+	return "3"
+})
+
+// coraza_get_matched_rules_cef formats the transaction's matched rules as
+// ArcSight CEF events, one line each, in match order:
+//
+//	CEF:0|OWASP|Coraza|<version>|<rule id>|<message>|<severity>|<extensions>
+//
+// The severity maps the rule's syslog severity onto CEF's 0-10 scale
+// (emergency to critical 10, error 8, warning 6, notice 4, info 2, debug 0),
+// or is "Unknown" for a rule without a severity action.
+// The extensions are externalId (the Coraza transaction ID), src,
+// requestMethod, request (the URI), msg, act ("block" for the rule that
+// interrupted the transaction, else "detect") and cs1 (the rule's logdata,
+// labelled "logdata"), where present. Returns "" when nothing matched, or nil
+// for an unknown handle. The caller must free the returned string.
+//
+//export coraza_get_matched_rules_cef
+func coraza_get_matched_rules_cef(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	tx := st.tx
+	method := ""
+	if vars, ok := txVariables(tx); ok {
+		method = vars.RequestMethod().Get()
+	}
+
+	var b strings.Builder
+	for _, mr := range tx.MatchedRules() {
+		rule := mr.Rule()
+		msg, data := st.maskMatched(mr, mr.Message()), st.maskMatched(mr, mr.Data())
+		name := msg
+		if name == "" {
+			name = "Rule " + strconv.Itoa(rule.ID()) + " matched"
+		}
+		fmt.Fprintf(&b, "CEF:0|OWASP|Coraza|%s|%d|%s|%s|",
+			cefHeaderEscaper.Replace(corazaVersion()), rule.ID(),
+			cefHeaderEscaper.Replace(name), cefSeverity(rule))
+
+		var ext []string
+		add := func(key, value string) {
+			if value != "" {
+				ext = append(ext, key+"="+cefExtensionEscaper.Replace(value))
+			}
+		}
+		add("externalId", mr.TransactionID())
+		add("src", mr.ClientIPAddress())
+		add("requestMethod", method)
+		add("request", mr.URI())
+		add("msg", msg)
+		if it := tx.Interruption(); it != nil && it.RuleID == rule.ID() {
+			add("act", "block")
+		} else {
+			add("act", "detect")
+		}
+		if data != "" {
+			add("cs1Label", "logdata")
+			add("cs1", data)
+		}
+		b.WriteString(strings.Join(ext, " "))
+		b.WriteByte('\n')
+	}
+	return C.CString(b.String())
+}
+
+// cefSeverity maps the syslog-style severity of a rule onto CEF's 0-10
+// scale.
+func cefSeverity(rule types.RuleMetadata) string {
+	s, ok := ruleSeverity(rule)
+	switch {
+	case !ok:
+		return "Unknown"
+	case s <= types.RuleSeverityCritical:
+		return "10"
+	case s == types.RuleSeverityError:
+		return "8"
+	case s == types.RuleSeverityWarning:
+		return "6"
+	case s == types.RuleSeverityNotice:
+		return "4"
+	case s == types.RuleSeverityInfo:
+		return "2"
+	}
+	return "0"
+}
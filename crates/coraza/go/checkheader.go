@@ -0,0 +1,39 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// coraza_check_header runs the request headers phase of a WAF on a bare
+// "GET / HTTP/1.1" request carrying a single header, e.g. to screen a
+// suspicious X-Forwarded-For value before building a full transaction. Rules
+// see only that header: no connection, body or other headers, so those that
+// need them, such as CRS checks for a missing Host, may match too. The
+// transaction is discarded afterwards; it is never registered, the logging
+// phase is not run and no persistent collection is written, though the
+// interruption counts towards the WAF's statistics. Returns the interruption
+// status, 0 if the header passes (always, for a dry-run WAF or with
+// enforcement off), or -1 for an unknown WAF or an empty name.
+//
+//export coraza_check_header
+func coraza_check_header(wafID C.uint64_t, name, value *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	n := C.GoString(name)
+	if n == "" {
+		setLastError(errors.New("empty header name"))
+		return -1
+	}
+
+	st := &txState{tx: ws.engine().NewTransaction(), waf: ws}
+	defer st.tx.Close()
+	return st.processRequestHeaders("GET", "/", "HTTP/1.1", [][2]string{{n, C.GoString(value)}})
+}
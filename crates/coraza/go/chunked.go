@@ -0,0 +1,99 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_write_request_body feeds a chunk of the request body to the WAF
+// without finishing the request body phase; call coraza_process_request_body
+// once the body is complete. Returns the interruption status, 0 to continue,
+// or -1 on error.
+//
+//export coraza_write_request_body
+func coraza_write_request_body(txID C.uint64_t, body unsafe.Pointer, bodyLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	if bodyLen <= 0 || body == nil {
+		return 0
+	}
+	start := time.Now()
+	buf := C.GoBytes(body, bodyLen)
+	prev := st.lastInterruption
+	return st.notifyBodyInterruption(prev, st.guard(func() C.int {
+		defer st.track(types.PhaseRequestBody, start)
+		return st.writeRequestBody(buf)
+	}))
+}
+
+// coraza_write_response_body feeds a chunk of the response body to the WAF
+// without finishing the response body phase; call coraza_process_response_body
+// once the body is complete (or the stream closes). Returns the interruption
+// status, 0 to continue, or -1 on error.
+//
+//export coraza_write_response_body
+func coraza_write_response_body(txID C.uint64_t, body unsafe.Pointer, bodyLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	if bodyLen <= 0 || body == nil || st.upgraded {
+		return 0
+	}
+	start := time.Now()
+	buf := C.GoBytes(body, bodyLen)
+	prev := st.lastInterruption
+	return st.notifyBodyInterruption(prev, st.guard(func() C.int {
+		defer st.track(types.PhaseResponseBody, start)
+		return st.writeResponseBody(buf)
+	}))
+}
+
+func (st *txState) writeResponseBody(buf []byte) C.int {
+	st.responseBodyStarted = true
+	st.captureResponse(buf)
+
+	switch {
+	case st.responseStreaming:
+		return st.writeResponseStream(buf, false)
+	case st.responseEncoding != "":
+		// Compressed chunks cannot be inflated on their own; the body is
+		// inspected once it is complete.
+		st.inflate(buf)
+		return 0
+	}
+
+	if it, _, err := st.tx.WriteResponseBody(buf); it != nil {
+		return st.interrupted(types.PhaseResponseBody, it)
+	} else if err != nil {
+		return inspectionError()
+	}
+	return 0
+}
+
+// writeRequestBody hands a request body chunk to Coraza, which rejects or
+// truncates it at the transaction's request body limit.
+func (st *txState) writeRequestBody(buf []byte) C.int {
+	if !st.requestBodyStarted {
+		st.requestBodyStarted = true
+		st.args.open = false
+	}
+	st.captureRequest(buf)
+	buf = st.limitBodyArgs(buf)
+	if it, _, err := st.tx.WriteRequestBody(buf); it != nil {
+		return st.interrupted(types.PhaseRequestBody, it)
+	} else if err != nil {
+		return inspectionError()
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "strings"
+
+// defaultClassificationVar is the TX variable read by
+// coraza_client_classification unless the WAF sets another.
+const defaultClassificationVar = "ua_class"
+
+// coraza_set_client_classification_var sets the TX variable that
+// coraza_client_classification reads for this WAF's transactions, e.g. the
+// one a bot or scanner detection rule fills with setvar. The "tx." prefix is
+// optional; NULL or "" restores the default, tx.ua_class. Returns 0 on
+// success or -1 for an unknown WAF.
+//
+//export coraza_set_client_classification_var
+func coraza_set_client_classification_var(wafID C.uint64_t, name *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	key := strings.ToLower(C.GoString(name))
+	key = strings.TrimPrefix(key, "tx.")
+	if key == "" {
+		ws.classificationVar.Store(nil)
+		return 0
+	}
+	ws.classificationVar.Store(&key)
+	return 0
+}
+
+// coraza_client_classification returns the client classification that the
+// rules stored in the TX variable set with
+// coraza_set_client_classification_var (tx.ua_class by default), such as
+// "bot" or "scanner". It returns nil if the variable is unset or empty, or
+// for an unknown handle. The caller must free the returned string.
+//
+//export coraza_client_classification
+func coraza_client_classification(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return nil
+	}
+	key := defaultClassificationVar
+	if k := st.waf.classificationVar.Load(); k != nil {
+		key = *k
+	}
+	v := vars.TX().Get(key)
+	if len(v) == 0 || v[0] == "" {
+		return nil
+	}
+	return C.CString(v[0])
+}
@@ -0,0 +1,269 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sessionCollection is the persistent collection backing SESSION.
+const sessionCollection = "SESSION"
+
+// collectionStore holds a WAF's persistent collections, which outlive
+// individual transactions: collection name -> record key -> variable ->
+// value. Coraza v3 does not implement persistent storage itself.
+type collectionStore struct {
+	mu      sync.Mutex
+	records map[string]map[string]map[string]string
+}
+
+func newCollectionStore() *collectionStore {
+	return &collectionStore{records: make(map[string]map[string]map[string]string)}
+}
+
+// load returns a copy of a record, or nil if it does not exist.
+func (c *collectionStore) load(collection, key string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record, ok := c.records[collection][key]
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string, len(record))
+	for k, v := range record {
+		out[k] = v
+	}
+	return out
+}
+
+// store replaces a record; the last transaction to finish wins.
+func (c *collectionStore) store(collection, key string, record map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.records[collection] == nil {
+		c.records[collection] = make(map[string]map[string]string)
+	}
+	c.records[collection][key] = record
+}
+
+// keys returns the record keys of a collection in sorted order.
+func (c *collectionStore) keys(collection string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.records[collection]))
+	for k := range c.records[collection] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// snapshot returns a deep copy of every collection.
+func (c *collectionStore) snapshot() map[string]map[string]map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]map[string]map[string]string, len(c.records))
+	for name, records := range c.records {
+		out[name] = make(map[string]map[string]string, len(records))
+		for key, record := range records {
+			cp := make(map[string]string, len(record))
+			for k, v := range record {
+				cp[k] = v
+			}
+			out[name][key] = cp
+		}
+	}
+	return out
+}
+
+// remove deletes a record, reporting whether it existed.
+func (c *collectionStore) remove(collection, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.records[collection][key]; !ok {
+		return false
+	}
+	delete(c.records[collection], key)
+	return true
+}
+
+// clear deletes every record.
+func (c *collectionStore) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.records)
+}
+
+// sessionVarPrefix is the TX collection prefix under which the variables of
+// the bound SESSION record are exposed to rules, e.g. TX:session.counter.
+const sessionVarPrefix = "session."
+
+// coraza_set_session_id binds the transaction to the SESSION collection record
+// for sessionID, like ModSecurity's setsid. The record's variables are loaded
+// into the TX collection under the "session." prefix, where rules can read and
+// update them (setvar:tx.session.counter=+1). Changes are saved back to the
+// record when the transaction is freed. Returns 0 on success or -1 for an
+// unknown handle or empty session ID.
+//
+//export coraza_set_session_id
+func coraza_set_session_id(txID C.uint64_t, sessionID *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	id := C.GoString(sessionID)
+	if id == "" {
+		return -1
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return -1
+	}
+
+	st.sessionID = id
+	for name, value := range st.waf.collections.load(sessionCollection, id) {
+		vars.TX().Set(sessionVarPrefix+name, []string{value})
+	}
+	return 0
+}
+
+// coraza_get_session_var returns the current value of a variable of the
+// transaction's SESSION record, or nil if it is unset. The caller must free
+// the returned string.
+//
+//export coraza_get_session_var
+func coraza_get_session_var(txID C.uint64_t, name *C.char) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return nil
+	}
+	v := vars.TX().Get(sessionVarPrefix + C.GoString(name))
+	if len(v) == 0 {
+		return nil
+	}
+	return C.CString(v[0])
+}
+
+// coraza_set_session_var sets a variable of the transaction's SESSION record.
+// Returns 0 on success or -1 for an unknown handle or a transaction without a
+// session ID.
+//
+//export coraza_set_session_var
+func coraza_set_session_var(txID C.uint64_t, name, value *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || st.sessionID == "" {
+		return -1
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return -1
+	}
+	vars.TX().Set(sessionVarPrefix+C.GoString(name), []string{C.GoString(value)})
+	return 0
+}
+
+// saveSession writes the transaction's session variables back to its SESSION
+// record.
+func (st *txState) saveSession() {
+	if st.sessionID == "" || st.waf.dryRun {
+		return
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return
+	}
+	record := make(map[string]string)
+	for _, md := range vars.TX().FindAll() {
+		if name, ok := strings.CutPrefix(md.Key(), sessionVarPrefix); ok {
+			record[name] = md.Value()
+		}
+	}
+	st.waf.collections.store(sessionCollection, st.sessionID, record)
+}
+
+// coraza_collection_keys_json returns the record keys of one of the WAF's
+// persistent collections (e.g. "SESSION") as a sorted JSON array; an unknown
+// or empty collection yields "[]". Returns nil for an unknown WAF. The caller
+// must free the returned string.
+//
+//export coraza_collection_keys_json
+func coraza_collection_keys_json(wafID C.uint64_t, collection *C.char) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+	return jsonCString(ws.collections.keys(strings.ToUpper(C.GoString(collection))))
+}
+
+// coraza_export_collections stores in *out the state of every persistent
+// collection of the WAF as a JSON object: collection name -> record key ->
+// variable -> value. The caller must free *out. Returns 0 on success or -1
+// for an unknown WAF or if out is nil.
+//
+//export coraza_export_collections
+func coraza_export_collections(wafID C.uint64_t, out **C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || out == nil {
+		return -1
+	}
+	*out = jsonCString(ws.collections.snapshot())
+	return 0
+}
+
+// coraza_import_collections loads persistent collection state produced by
+// coraza_export_collections into the WAF, e.g. to carry rate-limit counters
+// over to the WAF replacing it on a reload. Imported records replace existing
+// records with the same collection and key; others are kept. Returns the
+// number of records imported, -1 for an unknown WAF, or -1 and sets the last
+// error for malformed JSON.
+//
+//export coraza_import_collections
+func coraza_import_collections(wafID C.uint64_t, in *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	var state map[string]map[string]map[string]string
+	if err := json.Unmarshal([]byte(C.GoString(in)), &state); err != nil {
+		setLastError(fmt.Errorf("invalid collections JSON: %w", err))
+		return -1
+	}
+	n := 0
+	for name, records := range state {
+		for key, record := range records {
+			if record == nil {
+				record = map[string]string{}
+			}
+			ws.collections.store(strings.ToUpper(name), key, record)
+			n++
+		}
+	}
+	return C.int(n)
+}
+
+// coraza_collection_clear deletes the record stored under key in one of the
+// WAF's persistent collections, e.g. to reset a counter after a false
+// positive. A transaction still holding the record writes it back when
+// freed. Returns 0 if the record was deleted or -1 for an unknown WAF or if
+// it did not exist.
+//
+//export coraza_collection_clear
+func coraza_collection_clear(wafID C.uint64_t, collection, key *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || !ws.collections.remove(strings.ToUpper(C.GoString(collection)), C.GoString(key)) {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+func TestSessionPerWAF(t *testing.T) {
+	wafA := newTestWAF(t, "SecRuleEngine On")
+	wafB := newTestWAF(t, "SecRuleEngine On")
+	// session returns a transaction on waf bound to session "s1".
+	session := func(waf uint64) uint64 {
+		t.Helper()
+		tx := uint64(callInt(coraza_new_transaction, waf))
+		if got := callInt(coraza_set_session_id, tx, "s1"); got != 0 {
+			t.Fatalf("coraza_set_session_id = %d", got)
+		}
+		return tx
+	}
+
+	tx := session(wafA)
+	callInt(coraza_set_session_var, tx, "hits", "3")
+	call(coraza_free_transaction, tx)
+
+	tx = session(wafA)
+	if got, _ := callString(coraza_get_session_var, tx, "hits"); got != "3" {
+		t.Errorf("same WAF: hits = %q, want 3", got)
+	}
+	call(coraza_free_transaction, tx)
+	tx = session(wafB)
+	if got, ok := callString(coraza_get_session_var, tx, "hits"); ok {
+		t.Errorf("other WAF: hits = %q, want unset", got)
+	}
+	call(coraza_free_transaction, tx)
+	if got, _ := callString(coraza_collection_keys_json, wafA, "session"); got != `["s1"]` {
+		t.Errorf("WAF A keys = %s, want [\"s1\"]", got)
+	}
+
+	var out unsafe.Pointer
+	if got := callInt(coraza_export_collections, wafA, unsafe.Pointer(&out)); got != 0 {
+		t.Fatalf("coraza_export_collections = %d", got)
+	}
+	exported := unsafe.String((*byte)(out), bytes.IndexByte(unsafe.Slice((*byte)(out), 1<<16), 0))
+	if want := `{"SESSION":{"s1":{"hits":"3"}}}`; exported != want {
+		t.Fatalf("exported %s, want %s", exported, want)
+	}
+	wafC := newTestWAF(t, "SecRuleEngine On")
+	if got := callInt(coraza_import_collections, wafC, exported); got != 1 {
+		t.Fatalf("coraza_import_collections = %d, want 1", got)
+	}
+	tx = session(wafC)
+	if got, _ := callString(coraza_get_session_var, tx, "hits"); got != "3" {
+		t.Errorf("imported: hits = %q, want 3", got)
+	}
+	call(coraza_free_transaction, tx)
+
+	val, _ := wafInstances.Load(wafA)
+	ws := val.(*wafState)
+	call(coraza_free_waf, wafA)
+	if keys := ws.collections.keys(sessionCollection); len(keys) != 0 {
+		t.Errorf("freed WAF still holds sessions %q", keys)
+	}
+	if _, ok := callString(coraza_collection_keys_json, wafA, "session"); ok {
+		t.Error("coraza_collection_keys_json returned keys for a freed WAF")
+	}
+	if got := callInt(coraza_collection_clear, wafA, "session", "s1"); got != -1 {
+		t.Errorf("coraza_collection_clear on a freed WAF = %d, want -1", got)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+// coraza_process_connection sets the connection variables (REMOTE_ADDR,
+// REMOTE_PORT, SERVER_ADDR and SERVER_PORT) of a transaction. Call it before
+// coraza_process_request_headers so phase 1 rules, e.g. IP blocklists or
+// @geoLookup, see the client address. Returns 0 on success or -1 for an
+// unknown handle.
+//
+//export coraza_process_connection
+func coraza_process_connection(txID C.uint64_t, clientIP *C.char, clientPort C.int, serverIP *C.char, serverPort C.int) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	tx.ProcessConnection(C.GoString(clientIP), int(clientPort), C.GoString(serverIP), int(serverPort))
+	return 0
+}
+
+// coraza_remote_addr returns the client address the WAF attributes the
+// transaction to, the REMOTE_ADDR variable as it stands: the clientIP given
+// to coraza_process_connection unless something has since replaced it. It
+// returns "" if no address was set, or nil for an unknown handle. The caller
+// must free the returned string.
+//
+//export coraza_remote_addr
+func coraza_remote_addr(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return C.CString("")
+	}
+	return C.CString(vars.RemoteAddr().Get())
+}
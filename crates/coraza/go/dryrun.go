@@ -0,0 +1,66 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_new_waf_dryrun is coraza_new_waf for replaying traffic against a
+// ruleset. Rules are evaluated as if the engine were enforcing and the
+// would-be interruption is reported by the interruption getters, but the
+// phase functions return 0, the blocking getters report no interruption and
+// SESSION is never written. Returns 0 and sets the last error on failure.
+//
+//export coraza_new_waf_dryrun
+func coraza_new_waf_dryrun(directives *C.char) C.uint64_t {
+	directivesStr := C.GoString(directives) + "\nSecRuleEngine On"
+
+	cfg := coraza.NewWAFConfig().WithDirectives(directivesStr)
+	ws := newWAF(cfg, []string{directivesStr})
+	if ws == nil {
+		return 0
+	}
+	ws.dryRun = true
+	return C.uint64_t(registerWAF(ws))
+}
+
+// coraza_set_enforcement is a kill switch for a WAF whose rules are blocking
+// legitimate traffic: with on set to 0, every phase function and
+// coraza_finalize return 0 instead of an interruption status, taking effect
+// immediately, including for transactions in progress. Rules are still
+// evaluated as on a dry-run WAF (see coraza_new_waf_dryrun), so matched rules
+// and the interruption that would have been returned are recorded for
+// analysis, and the getters telling the host how to block report none, but
+// unlike DetectionOnly no rebuild is needed. Inspection errors
+// still follow coraza_set_fail_mode. Pass a non-zero on to enforce again.
+// Returns 0 on success or -1 for an unknown WAF.
+//
+//export coraza_set_enforcement
+func coraza_set_enforcement(wafID C.uint64_t, on C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	ws.enforcementOff.Store(on == 0)
+	return 0
+}
+
+// passThrough reports whether interruptions are recorded but not returned.
+func (ws *wafState) passThrough() bool {
+	return ws.dryRun || ws.enforcementOff.Load()
+}
+
+// enforcedInterruption returns the transaction's interruption, or nil if
+// there is none or the WAF only records it; see passThrough.
+func (st *txState) enforcedInterruption() *types.Interruption {
+	if st.waf.passThrough() {
+		return nil
+	}
+	return st.tx.Interruption()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+// engines maps the current internal Coraza WAF of every live wafState to
+// it: map[*corazawaf.WAF]*wafState, with the key held as an interface value
+// so the engine cannot be collected and its address reused while it is
+// registered. Plugins such as @geoLookup only see the transaction, and find
+// their WAF's settings through it.
+var engines sync.Map
+
+// registerEngine records waf as the current engine of ws, replacing the one
+// it was built to supersede, which is then free to be collected once its
+// transactions are done. Those still running see @geoLookup as without a
+// database. configMu must be held.
+func (ws *wafState) registerEngine(waf coraza.WAF) {
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	w, ok := internalWAF(tx)
+	if !ok {
+		return
+	}
+	key := w.Interface()
+	engines.Store(key, ws)
+	if ws.engineKey != nil && ws.engineKey != key {
+		engines.Delete(ws.engineKey)
+	}
+	ws.engineKey = key
+}
+
+// unregisterEngine forgets the engine of ws once it is freed.
+func (ws *wafState) unregisterEngine() {
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	if ws.engineKey != nil {
+		engines.Delete(ws.engineKey)
+		ws.engineKey = nil
+	}
+}
+
+// txWAFState returns the state of the WAF a transaction was created from, as
+// long as its engine is the WAF's current one.
+func txWAFState(tx any) (*wafState, bool) {
+	w, ok := internalWAF(tx)
+	if !ok {
+		return nil, false
+	}
+	val, ok := engines.Load(w.Interface())
+	if !ok {
+		return nil, false
+	}
+	return val.(*wafState), true
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEnginesFollowRebuilds(t *testing.T) {
+	w1 := newTestWAF(t, "SecRuleEngine On")
+	w2 := newTestWAF(t, "SecRuleEngine On")
+	val, _ := wafInstances.Load(w1)
+	ws1 := val.(*wafState)
+	val, _ = wafInstances.Load(w2)
+	ws2 := val.(*wafState)
+
+	registered := func(ws *wafState) int {
+		n := 0
+		engines.Range(func(_, v any) bool {
+			if v == ws {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+
+	for i := 1; i <= 3; i++ {
+		rule := fmt.Sprintf(`SecRule ARGS "@rx x%d" "id:%d,phase:1,pass"`, i, i)
+		if got := callInt(coraza_add_rule, w1, rule, nil); got != 0 {
+			t.Fatalf("coraza_add_rule = %d: %s", got, lastError)
+		}
+		if got := registered(ws1); got != 1 {
+			t.Fatalf("after rebuild %d: %d engines registered, want 1", i, got)
+		}
+	}
+	tx := ws1.engine().NewTransaction()
+	if ws, ok := txWAFState(tx); !ok || ws != ws1 {
+		t.Errorf("current engine resolves to %v, %v; want the rebuilt WAF", ws, ok)
+	}
+	tx.Close()
+
+	call(coraza_free_waf, w1)
+	if got := registered(ws1); got != 0 {
+		t.Errorf("after free: %d engines registered, want 0", got)
+	}
+	tx = ws2.engine().NewTransaction()
+	defer tx.Close()
+	if ws, ok := txWAFState(tx); !ok || ws != ws2 {
+		t.Errorf("other WAF resolves to %v, %v after free; want it unaffected", ws, ok)
+	}
+}
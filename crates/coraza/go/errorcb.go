@@ -0,0 +1,44 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+typedef void (*coraza_error_cb)(const char *message);
+
+static inline void coraza_call_error_cb(coraza_error_cb cb, const char *message) {
+	cb(message);
+}
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// errorCallback is the C function notified of bridge errors, or nil.
+var errorCallback atomic.Pointer[byte]
+
+// coraza_set_error_callback registers cb to be called with a message for
+// each problem the bridge deals with on the host's behalf and no call
+// reports, such as a transaction closed by coraza_sweep_stale_transactions.
+// It runs on the thread that hit the problem. The message is only valid
+// during the call. Pass NULL to unregister.
+//
+//export coraza_set_error_callback
+func coraza_set_error_callback(cb C.coraza_error_cb) {
+	errorCallback.Store((*byte)(unsafe.Pointer(cb)))
+}
+
+// reportError passes msg to the error callback, if any. It is a variable so
+// tests can observe the messages.
+var reportError = func(msg string) {
+	cb := errorCallback.Load()
+	if cb == nil {
+		return
+	}
+	cmsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cmsg))
+	C.coraza_call_error_cb(C.coraza_error_cb(unsafe.Pointer(cb)), cmsg)
+}
@@ -0,0 +1,53 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "sync/atomic"
+
+// Fail modes for coraza_set_fail_mode. Until a mode is set, internal errors
+// are reported to the caller as -1.
+const (
+	failModeUnset  = -1
+	failModeOpen   = 0
+	failModeClosed = 1
+
+	// failClosedStatus is returned for internal errors in fail-closed mode.
+	failClosedStatus = 403
+)
+
+var failMode atomic.Int32
+
+func init() {
+	failMode.Store(failModeUnset)
+}
+
+// coraza_set_fail_mode selects how phase functions report internal inspection
+// errors (as opposed to interruptions): 0 fails open and lets the request
+// through, 1 fails closed and returns a 403 block status. Returns 0 on success
+// or -1 for an invalid mode.
+//
+//export coraza_set_fail_mode
+func coraza_set_fail_mode(mode C.int) C.int {
+	switch mode {
+	case failModeOpen, failModeClosed:
+		failMode.Store(int32(mode))
+		return 0
+	}
+	return -1
+}
+
+// inspectionError returns the status a phase function reports when Coraza
+// fails to inspect the transaction, according to the configured fail mode.
+func inspectionError() C.int {
+	switch failMode.Load() {
+	case failModeOpen:
+		return 0
+	case failModeClosed:
+		return failClosedStatus
+	}
+	return -1
+}
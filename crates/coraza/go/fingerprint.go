@@ -0,0 +1,67 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// fingerprintHeaders are the request headers that take part in
+// coraza_request_fingerprint.
+var fingerprintHeaders = []string{"host", "content-type", "user-agent"}
+
+// coraza_request_fingerprint returns a hex SHA-256 digest of the request as
+// Coraza normalized it, for grouping identical attempts: the method, the
+// decoded path (REQUEST_FILENAME), every argument (ARGS, sorted by name and
+// value, so their order does not matter) and the Host, Content-Type and
+// User-Agent headers. Call it after the request phases; body arguments only
+// count once coraza_process_request_body has run. Requests that differ only
+// in other headers, cookies or the connection get the same fingerprint.
+// Returns nil for an unknown handle. The caller must free the returned
+// string.
+//
+//export coraza_request_fingerprint
+func coraza_request_fingerprint(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return nil
+	}
+
+	h := sha256.New()
+	writeHashString(h, vars.RequestMethod().Get())
+	writeHashString(h, vars.RequestFilename().Get())
+
+	var args [][2]string
+	for _, md := range vars.Args().FindAll() {
+		args = append(args, [2]string{md.Key(), md.Value()})
+	}
+	sort.Slice(args, func(i, j int) bool {
+		if args[i][0] != args[j][0] {
+			return args[i][0] < args[j][0]
+		}
+		return args[i][1] < args[j][1]
+	})
+	for _, a := range args {
+		writeHashString(h, a[0])
+		writeHashString(h, a[1])
+	}
+
+	headers := vars.RequestHeaders()
+	for _, name := range fingerprintHeaders {
+		for _, v := range headers.Get(name) {
+			writeHashString(h, name)
+			writeHashString(h, v)
+		}
+	}
+	return C.CString(hex.EncodeToString(h.Sum(nil)))
+}
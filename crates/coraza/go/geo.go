@@ -0,0 +1,152 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoRecord is the subset of a GeoIP2/GeoLite2 City or Country record that
+// fills the GEO collection.
+type geoRecord struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+func init() {
+	// Coraza's own @geoLookup matches unconditionally without a database.
+	plugins.RegisterOperator("geoLookup", func(plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		return geoLookup{}, nil
+	})
+}
+
+// coraza_set_geoip_db loads a MaxMind GeoIP2 or GeoLite2 City or Country
+// database (.mmdb) for the WAF's @geoLookup rules, replacing any previous one.
+// @geoLookup then resolves its input, typically REMOTE_ADDR as set by
+// coraza_process_connection, and on success fills GEO with COUNTRY_CODE,
+// COUNTRY_NAME, COUNTRY_CONTINENT, REGION, CITY, POSTAL_CODE, LATITUDE and
+// LONGITUDE (those the database has) and matches; addresses not in the
+// database do not match. Without a database, @geoLookup keeps Coraza's
+// behaviour of always matching and leaves GEO empty. The file is read into
+// memory. Returns 0 on success or -1 for an unknown WAF or a missing or
+// invalid file (see coraza_last_error).
+//
+//export coraza_set_geoip_db
+func coraza_set_geoip_db(wafID C.uint64_t, path *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	data, err := os.ReadFile(C.GoString(path))
+	if err != nil {
+		setLastError(err)
+		return -1
+	}
+	db, err := maxminddb.FromBytes(data)
+	if err != nil {
+		setLastError(fmt.Errorf("invalid GeoIP database: %w", err))
+		return -1
+	}
+
+	ws.geoDB.Store(db)
+	return 0
+}
+
+// geoLookup implements @geoLookup against the WAF's GeoIP database.
+type geoLookup struct{}
+
+func (geoLookup) Evaluate(tx plugintypes.TransactionState, value string) bool {
+	ws, ok := txWAFState(tx)
+	if !ok {
+		return true
+	}
+	db := ws.geoDB.Load()
+	if db == nil {
+		return true
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	var rec geoRecord
+	if err := db.Lookup(ip, &rec); err != nil || rec.Country.ISOCode == "" {
+		return false
+	}
+
+	geo := tx.Variables().Geo()
+	set := func(key, value string) {
+		if value != "" {
+			geo.Set(key, []string{value})
+		}
+	}
+	set("COUNTRY_CODE", rec.Country.ISOCode)
+	set("COUNTRY_NAME", rec.Country.Names["en"])
+	set("COUNTRY_CONTINENT", rec.Continent.Code)
+	if len(rec.Subdivisions) > 0 {
+		region := rec.Subdivisions[0].Names["en"]
+		if region == "" {
+			region = rec.Subdivisions[0].ISOCode
+		}
+		set("REGION", region)
+	}
+	set("CITY", rec.City.Names["en"])
+	set("POSTAL_CODE", rec.Postal.Code)
+	if rec.Location.Latitude != nil && rec.Location.Longitude != nil {
+		set("LATITUDE", strconv.FormatFloat(*rec.Location.Latitude, 'f', -1, 64))
+		set("LONGITUDE", strconv.FormatFloat(*rec.Location.Longitude, 'f', -1, 64))
+	}
+	return true
+}
+
+// coraza_get_geo_json returns the result of the transaction's @geoLookup as
+// a JSON object with the GEO variables that were resolved, under lowercase
+// keys: "country_code", "country_name", "country_continent", "region",
+// "city", "postal_code", "latitude" and "longitude". It returns "{}" if no
+// lookup succeeded, or nil for an unknown handle. The caller must free the
+// returned string.
+//
+//export coraza_get_geo_json
+func coraza_get_geo_json(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	geo := map[string]string{}
+	if vars, ok := txVariables(tx); ok {
+		for _, md := range vars.Geo().FindAll() {
+			geo[strings.ToLower(md.Key())] = md.Value()
+		}
+	}
+	return jsonCString(geo)
+}
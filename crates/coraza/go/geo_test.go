@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+// geoTestDB is a GeoIP2-City database written with mmdbwriter that maps
+// 81.2.69.0/24 to London, GB, as MaxMind's own test database does.
+const geoTestDB = "testdata/geoip-city-test.mmdb"
+
+func TestGeoLookup(t *testing.T) {
+	tests := []struct {
+		ip          string
+		wantStatus  int64
+		wantCountry string
+		wantGeo     string
+	}{
+		{"81.2.69.142", 403, "GB", `{"city":"London","country_code":"GB","country_continent":"EU",` +
+			`"country_name":"United Kingdom","latitude":"51.5142","longitude":"-0.0931","region":"England"}`},
+		{"192.0.2.1", 0, "", "{}"},
+		{"2001:db8::1", 0, "", "{}"},
+	}
+
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRule REMOTE_ADDR "@geoLookup" "id:1,phase:1,deny,status:403,chain"
+	SecRule GEO:COUNTRY_CODE "@streq GB"
+SecRule REMOTE_ADDR "!@geoLookup" "id:2,phase:1,pass,nolog,setvar:tx.geo_miss=1"`)
+	if got := callInt(coraza_set_geoip_db, waf, geoTestDB); got != 0 {
+		t.Fatalf("coraza_set_geoip_db = %d: %s", got, lastError)
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			callInt(coraza_process_connection, tx, tt.ip, 1234, "192.0.2.2", 80)
+			if got := callInt(coraza_process_request_headers, tx, "GET", "/", "HTTP/1.1", "[]"); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if got := collectionValue(t, tx, "GEO", "COUNTRY_CODE"); got != tt.wantCountry {
+				t.Errorf("GEO:COUNTRY_CODE = %q, want %q", got, tt.wantCountry)
+			}
+			if miss := collectionValue(t, tx, "TX", "geo_miss") == "1"; miss != (tt.wantCountry == "") {
+				t.Errorf("@geoLookup matched = %v, want %v", !miss, tt.wantCountry != "")
+			}
+			if got, _ := callString(coraza_get_geo_json, tx); got != tt.wantGeo {
+				t.Errorf("coraza_get_geo_json = %s, want %s", got, tt.wantGeo)
+			}
+		})
+	}
+}
+
+func TestGeoLookupInvalidDB(t *testing.T) {
+	waf := newTestWAF(t, "SecRuleEngine On")
+	for _, path := range []string{"testdata/missing.mmdb", "geo_test.go"} {
+		if got := callInt(coraza_set_geoip_db, waf, path); got != -1 {
+			t.Errorf("coraza_set_geoip_db(%s) = %d, want -1", path, got)
+		}
+	}
+}
@@ -2,7 +2,10 @@ module github.com/layer7waf/coraza-bridge
 
 go 1.22
 
-require github.com/corazawaf/coraza/v3 v3.2.1
+require (
+	github.com/corazawaf/coraza/v3 v3.2.1
+	github.com/oschwald/maxminddb-golang v1.13.1
+)
 
 require (
 	github.com/corazawaf/libinjection-go v0.2.1 // indirect
@@ -13,5 +16,6 @@ require (
 	github.com/tidwall/pretty v1.2.1 // indirect
 	golang.org/x/net v0.26.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 	rsc.io/binaryregexp v0.2.0 // indirect
 )
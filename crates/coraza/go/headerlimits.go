@@ -0,0 +1,101 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"net/http"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// headerLimits bounds the headers of a request or response; 0 means no
+// limit.
+type headerLimits struct {
+	maxCount      int
+	maxTotalBytes int64
+}
+
+// headerCounter tracks the headers added to one side of a transaction
+// against the limits.
+type headerCounter struct {
+	count int
+	bytes int64
+	// exceeded is set once a limit was hit.
+	exceeded bool
+}
+
+// TX variables set when a request or response exceeds the header limits.
+const (
+	headersExceededVar         = "request_headers_exceeded"
+	responseHeadersExceededVar = "response_headers_exceeded"
+)
+
+// coraza_set_header_limits caps the request and response headers of this
+// WAF's new transactions at maxCount fields and maxTotalBytes bytes of names
+// and values, each side counted on its own, trailers included. Headers past a
+// limit are dropped. A request over a limit sets TX:request_headers_exceeded
+// and, with SecRuleEngine On, is interrupted with status 431; a response sets
+// TX:response_headers_exceeded and is interrupted with status 502. Pass 0 for
+// no limit. Returns 0 on success or -1 for an unknown WAF or a negative
+// limit.
+//
+//export coraza_set_header_limits
+func coraza_set_header_limits(wafID C.uint64_t, maxCount C.int, maxTotalBytes C.int64_t) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || maxCount < 0 || maxTotalBytes < 0 {
+		return -1
+	}
+	if maxCount == 0 && maxTotalBytes == 0 {
+		ws.headerLimits.Store(nil)
+		return 0
+	}
+	ws.headerLimits.Store(&headerLimits{maxCount: int(maxCount), maxTotalBytes: int64(maxTotalBytes)})
+	return 0
+}
+
+// addRequestHeader adds a request header unless it exceeds the WAF's header
+// limits.
+func (st *txState) addRequestHeader(name, value string) {
+	if st.limitHeader(&st.headers, name, value, headersExceededVar, http.StatusRequestHeaderFieldsTooLarge) {
+		st.tx.AddRequestHeader(name, value)
+	}
+}
+
+// addResponseHeader adds a response header unless it exceeds the WAF's
+// header limits.
+func (st *txState) addResponseHeader(name, value string) {
+	if st.limitHeader(&st.responseHeaders, name, value, responseHeadersExceededVar, http.StatusBadGateway) {
+		st.tx.AddResponseHeader(name, value)
+	}
+}
+
+// limitHeader counts a header against the WAF's header limits and reports
+// whether it may be added. The first header over a limit sets the TX
+// variable flag and interrupts the transaction with status.
+func (st *txState) limitHeader(c *headerCounter, name, value, flag string, status int) bool {
+	l := st.waf.headerLimits.Load()
+	if l == nil {
+		return true
+	}
+	if c.exceeded {
+		return false
+	}
+	count, size := c.count+1, c.bytes+int64(len(name)+len(value))
+	if (l.maxCount > 0 && count > l.maxCount) || (l.maxTotalBytes > 0 && size > l.maxTotalBytes) {
+		c.exceeded = true
+		if vars, ok := txVariables(st.tx); ok {
+			vars.TX().Set(flag, []string{"1"})
+		}
+		if ts, ok := st.tx.(plugintypes.TransactionState); ok {
+			ts.Interrupt(&types.Interruption{Status: status, Action: "deny"})
+		}
+		return false
+	}
+	c.count, c.bytes = count, size
+	return true
+}
@@ -0,0 +1,134 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errInflated tells a writer to an inflater that no more input is wanted.
+var errInflated = errors.New("response body already inflated")
+
+// inflater decompresses a response body as its chunks arrive, so neither the
+// compressed nor the inflated body is held past the response body limit.
+type inflater struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// newInflater starts decoding a body in the given Content-Encoding, keeping
+// at most limit+1 inflated bytes so Coraza still sees that the body went
+// over its limit.
+func newInflater(encoding string, limit int64) *inflater {
+	pr, pw := io.Pipe()
+	in := &inflater{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(in.done)
+		in.body, in.err = decompressBody(pr, encoding, limit)
+		// Unblock the writer once the limit is reached or the input is bad.
+		pr.CloseWithError(errInflated)
+	}()
+	return in
+}
+
+// inflate passes a chunk of the compressed response body to the
+// transaction's inflater, starting it on the first chunk.
+func (st *txState) inflate(buf []byte) {
+	if st.inflater == nil {
+		st.inflater = newInflater(st.responseEncoding, responseBodyLimit(st.tx))
+	}
+	st.inflater.write(buf)
+}
+
+// write feeds a compressed chunk to the decoder. Input past the point where
+// the limit was reached, or the stream failed, is discarded.
+func (in *inflater) write(buf []byte) {
+	if len(buf) > 0 {
+		in.pw.Write(buf)
+	}
+}
+
+// finish ends the input and returns the inflated body.
+func (in *inflater) finish() ([]byte, error) {
+	in.pw.Close()
+	<-in.done
+	return in.body, in.err
+}
+
+// abort stops the decoder without waiting for it, for a transaction closed
+// before its response body completed.
+func (in *inflater) abort() {
+	in.pw.CloseWithError(errInflated)
+}
+
+// decompressBody inflates a gzip or deflate encoded body. At most limit+1
+// bytes are produced so a decompression bomb cannot exhaust memory.
+func decompressBody(r io.Reader, encoding string, limit int64) ([]byte, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some servers send raw DEFLATE.
+		br := bufio.NewReader(r)
+		if hdr, err := br.Peek(2); err == nil && isZlibHeader(hdr) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			r = zr
+		} else {
+			fr := flate.NewReader(br)
+			defer fr.Close()
+			r = fr
+		}
+	}
+	return io.ReadAll(io.LimitReader(r, limit+1))
+}
+
+// isZlibHeader reports whether hdr is a zlib header for a DEFLATE stream
+// (RFC 1950, section 2.2).
+func isZlibHeader(hdr []byte) bool {
+	return hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0
+}
+
+// coraza_set_response_content_encoding declares the Content-Encoding of the
+// response body so coraza_process_response_body can inspect the decompressed
+// content. Supported values are gzip, x-gzip, deflate and identity (or empty).
+// Returns 0 on success or -1 for an unknown handle or unsupported encoding.
+//
+//export coraza_set_response_content_encoding
+func coraza_set_response_content_encoding(txID C.uint64_t, encoding *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+
+	enc := strings.ToLower(strings.TrimSpace(C.GoString(encoding)))
+	switch enc {
+	case "", "identity":
+		st.responseEncoding = ""
+	case "gzip", "x-gzip", "deflate":
+		st.responseEncoding = enc
+	default:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+// TestCompressedBodyOverLimit sends compressed response bodies that are
+// larger than the response body limit even before inflating, and checks the
+// part within the limit is still inspected.
+func TestCompressedBodyOverLimit(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain
+SecResponseBodyLimit 1024
+SecResponseBodyLimitAction ProcessPartial
+SecRule RESPONSE_BODY "@contains leak" "id:1,phase:4,deny,status:502"`)
+	noise := make([]byte, 16<<10)
+	rand.New(rand.NewSource(1)).Read(noise)
+	body := append([]byte("leak "), hex.EncodeToString(noise)...)
+
+	tests := []struct {
+		encoding string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+		{"raw deflate", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			var enc bytes.Buffer
+			zw := tt.compress(&enc)
+			zw.Write(body)
+			zw.Close()
+			if enc.Len() <= 1024 {
+				t.Fatalf("compressed body is %d bytes, want more than the limit", enc.Len())
+			}
+
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			request(tx)
+			callInt(coraza_process_response_headers, tx, 200, `[["Content-Type","text/plain"]]`)
+			encoding := tt.encoding
+			if encoding == "raw deflate" {
+				encoding = "deflate"
+			}
+			if got := callInt(coraza_set_response_content_encoding, tx, encoding); got != 0 {
+				t.Fatalf("coraza_set_response_content_encoding = %d", got)
+			}
+			for b := enc.Bytes(); len(b) > 0; b = b[min(len(b), 512):] {
+				chunk := b[:min(len(b), 512)]
+				if got := callInt(coraza_write_response_body, tx, unsafe.Pointer(&chunk[0]), len(chunk)); got != 0 {
+					t.Fatalf("coraza_write_response_body = %d", got)
+				}
+			}
+			if got := callInt(coraza_process_response_body, tx, nil, 0); got != 502 {
+				t.Errorf("status = %d, want 502", got)
+			}
+		})
+	}
+}
+
+// TestCompressedBodyFreedEarly frees a transaction part way through a
+// compressed response body.
+func TestCompressedBodyFreedEarly(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain`)
+	var enc bytes.Buffer
+	zw := gzip.NewWriter(&enc)
+	zw.Write(bytes.Repeat([]byte("x"), 4096))
+	zw.Close()
+	half := enc.Bytes()[:enc.Len()/2]
+
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	request(tx)
+	callInt(coraza_process_response_headers, tx, 200, `[["Content-Type","text/plain"]]`)
+	callInt(coraza_set_response_content_encoding, tx, "gzip")
+	callInt(coraza_write_response_body, tx, unsafe.Pointer(&half[0]), len(half))
+	val, _ := txInstances.Load(tx)
+	in := val.(*txState).inflater
+	call(coraza_free_transaction, tx)
+	<-in.done
+}
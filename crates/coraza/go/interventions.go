@@ -0,0 +1,141 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "github.com/corazawaf/coraza/v3/types"
+
+// coraza_get_all_interventions_json returns every interruption raised during
+// the transaction as a JSON array of {phase, status, action, rule_id}, plus
+// "step" for one raised by coraza_process_request or
+// coraza_process_response, in the order they occurred, or "[]" if there were
+// none. The caller must free the returned string.
+//
+//export coraza_get_all_interventions_json
+func coraza_get_all_interventions_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	if len(st.interruptions) == 0 {
+		return C.CString("[]")
+	}
+	return jsonCString(st.interruptions)
+}
+
+// coraza_response_interruption_json returns the interruption raised while
+// processing the response headers or body, as a JSON {phase, status, action,
+// rule_id} object, or nil if the response phases did not interrupt (including
+// when the request was already blocked) or the transaction is unknown. A
+// phase of 3 means the response headers can still be replaced; 4 means they
+// may already have been sent and only the body can be withheld. The caller
+// must free the returned string.
+//
+//export coraza_response_interruption_json
+func coraza_response_interruption_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	for _, pi := range st.interruptions {
+		if pi.Phase == int(types.PhaseResponseHeaders) || pi.Phase == int(types.PhaseResponseBody) {
+			return jsonCString(pi)
+		}
+	}
+	return nil
+}
+
+// coraza_is_disruptive returns 1 if the transaction's interruption carries a
+// disruptive action (deny, drop, redirect or block), i.e. it would stop the
+// request, 0 if rules only matched without disrupting it, or -1 for an
+// unknown handle. On a dry-run WAF it reports the interruption that would
+// have been returned; with SecRuleEngine DetectionOnly Coraza raises none, so
+// it is always 0.
+//
+//export coraza_is_disruptive
+func coraza_is_disruptive(txID C.uint64_t) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	it := tx.Interruption()
+	if it == nil {
+		return 0
+	}
+	switch it.Action {
+	case "deny", "drop", "redirect", "block":
+		return 1
+	}
+	return 0
+}
+
+type redirectTarget struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// coraza_redirect_json returns the target of a redirect interruption as a
+// JSON {url, status} object, where status is the rule's status if it is a
+// 3xx (301, 302, 303, 307 or 308) and 302 otherwise. It returns nil if the
+// transaction was not interrupted by a redirect or for an unknown handle.
+// The caller must free the returned string.
+//
+//export coraza_redirect_json
+func coraza_redirect_json(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+
+	it := tx.Interruption()
+	if it == nil || it.Action != "redirect" {
+		return nil
+	}
+
+	return jsonCString(redirectTarget{URL: it.Data, Status: redirectStatus(it.Status)})
+}
+
+type decisiveRule struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+	Action  string `json:"action"`
+	Status  int    `json:"status"`
+}
+
+// coraza_get_decisive_rule_json returns the rule whose disruptive action caused
+// the current interruption, as JSON. Returns nil if the transaction has not
+// been interrupted. The caller must free the returned string.
+//
+//export coraza_get_decisive_rule_json
+func coraza_get_decisive_rule_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+
+	rule := st.decisiveRule()
+	if rule == nil {
+		return nil
+	}
+	return jsonCString(rule)
+}
+
+// decisiveRule returns the rule behind the current interruption, or nil.
+func (st *txState) decisiveRule() *decisiveRule {
+	it := st.tx.Interruption()
+	if it == nil {
+		return nil
+	}
+
+	rule := &decisiveRule{ID: it.RuleID, Action: it.Action, Status: st.status(it)}
+	for _, mr := range st.tx.MatchedRules() {
+		if mr.Rule().ID() == it.RuleID {
+			rule.Message = st.maskMatched(mr, mr.Message())
+			break
+		}
+	}
+	return rule
+}
@@ -0,0 +1,68 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_process_request_body_json is coraza_process_request_body for a JSON
+// body the host has already parsed. body is a JSON object of the flattened
+// argument keys Coraza's JSON body processor would produce, e.g.
+//
+//	{"json.user.name": "a", "json.ids.0": "7", "json.ids.1": "8", "json.ids": "2"}
+//
+// Strings are used as is, null as "", and other values as their JSON text.
+// The arguments are added to ARGS_POST; REQUEST_BODY stays empty. Returns the
+// interruption status, 0 to continue, or -1 for an unknown handle or
+// malformed JSON.
+//
+//export coraza_process_request_body_json
+func coraza_process_request_body_json(txID C.uint64_t, flattenedJSON *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(C.GoString(flattenedJSON)), &fields); err != nil {
+		setLastError(fmt.Errorf("invalid flattened JSON: %w", err))
+		return -1
+	}
+	args := make([][2]string, 0, len(fields))
+	for key, raw := range fields {
+		value := string(raw)
+		switch {
+		case value == "null":
+			value = ""
+		case strings.HasPrefix(value, `"`):
+			if err := json.Unmarshal(raw, &value); err != nil {
+				setLastError(fmt.Errorf("invalid flattened JSON: %w", err))
+				return -1
+			}
+		}
+		args = append(args, [2]string{key, value})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i][0] < args[j][0] })
+
+	start := time.Now()
+	st.requestBodyStarted = true
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseRequestBody, start)
+		if vars, ok := txVariables(st.tx); ok {
+			for _, arg := range st.limitJSONArgs(args) {
+				vars.ArgsPost().SetIndex(arg[0], 0, arg[1])
+			}
+		}
+		return st.processRequestBody(nil)
+	})
+}
@@ -7,179 +7,804 @@ package main
 import "C"
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/types"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Coraza's default SecRequestBodyLimit and SecResponseBodyLimit.
+const (
+	defaultRequestBodyLimit  = 134217728
+	defaultResponseBodyLimit = 524288
 )
 
 var (
 	wafCounter uint64
 	txCounter  uint64
 
-	wafInstances sync.Map // map[uint64]coraza.WAF
-	txInstances  sync.Map // map[uint64]types.Transaction
+	wafInstances sync.Map // map[uint64]*wafState
+	txInstances  sync.Map // map[uint64]*txState
+
+	lastErrorMu sync.Mutex
+	lastError   string
 )
 
+func setLastError(err error) {
+	lastErrorMu.Lock()
+	lastError = err.Error()
+	lastErrorMu.Unlock()
+}
+
+// coraza_last_error returns the message of the most recent failure reported
+// by a bridge function, or nil if none occurred. The caller must free the
+// returned string.
+//
+//export coraza_last_error
+func coraza_last_error() *C.char {
+	lastErrorMu.Lock()
+	defer lastErrorMu.Unlock()
+	if lastError == "" {
+		return nil
+	}
+	return C.CString(lastError)
+}
+
 //export coraza_new_waf
 func coraza_new_waf(directives *C.char) C.uint64_t {
 	directivesStr := C.GoString(directives)
 
 	cfg := coraza.NewWAFConfig().WithDirectives(directivesStr)
-	waf, err := coraza.NewWAF(cfg)
-	if err != nil {
+	ws := newWAF(cfg, []string{directivesStr})
+	if ws == nil {
+		return 0
+	}
+	return C.uint64_t(registerWAF(ws))
+}
+
+// coraza_new_waf_from_files creates a WAF from a JSON array of directive file
+// paths, loaded in the given order. Relative Include directives resolve
+// against the directory of the file that contains them.
+//
+//export coraza_new_waf_from_files
+func coraza_new_waf_from_files(paths *C.char) C.uint64_t {
+	var files []string
+	if err := json.Unmarshal([]byte(C.GoString(paths)), &files); err != nil {
+		setLastError(fmt.Errorf("invalid paths JSON: %w", err))
+		return 0
+	}
+	if len(files) == 0 {
+		setLastError(errors.New("no directive files given"))
+		return 0
+	}
+
+	cfg := coraza.NewWAFConfig()
+	var sources []string
+	for _, f := range files {
+		cfg = cfg.WithDirectivesFromFile(f)
+		data, err := os.ReadFile(f)
+		if err != nil {
+			setLastError(err)
+			return 0
+		}
+		sources = append(sources, string(data))
+	}
+	ws := newWAF(cfg, sources)
+	if ws == nil {
 		return 0
 	}
+	return C.uint64_t(registerWAF(ws))
+}
+
+// wafState is the bridge-side record kept for each WAF.
+type wafState struct {
+	// waf holds the current coraza.WAF; see engine. coraza_add_rule
+	// replaces it, while transactions keep the one they were created with.
+	waf atomic.Value
+
+	// cfg is the configuration the current WAF was built from. configMu
+	// serializes rebuilding it and the settings that must be carried over:
+	// tmpDir, see coraza_set_tmp_dir, engineMode, see
+	// coraza_set_engine_mode, responseMimeTypes, see
+	// coraza_add_response_mime_type, and engineKey, the internal WAF of the
+	// current one, see engines. sources are the directive texts cfg
+	// holds, in order, with directive files by content; see
+	// coraza_get_ruleset_hash and coraza_default_action. reloadedAt is when
+	// the current WAF replaced the previous one, zero if it never has.
+	cfg               coraza.WAFConfig
+	configMu          sync.Mutex
+	tmpDir            string
+	engineMode        string
+	responseMimeTypes []string
+	engineKey         any
+	sources           []string
+	reloadedAt        time.Time
+
+	// createdAt is when the WAF was first built.
+	createdAt time.Time
+
+	// id is the WAF's handle and label the tenant label it was created
+	// with, if any.
+	id    uint64
+	label string
+
+	// activeTransactions, transactionsTotal and interruptionsTotal are the
+	// per-WAF counterparts of the process-wide counters.
+	activeTransactions atomic.Int64
+	transactionsTotal  atomic.Uint64
+	interruptionsTotal atomic.Uint64
+
+	// warnings were logged while the current ruleset was loaded; see
+	// coraza_waf_warnings_json. configMu guards them.
+	warnings []string
+
+	// dryRun suppresses blocking statuses and persistence writes.
+	dryRun bool
+
+	// enforcementOff suppresses blocking statuses like dryRun, but can be
+	// switched at any time; see coraza_set_enforcement.
+	enforcementOff atomic.Bool
+
+	// auditFormat selects the audit log serialization; see
+	// coraza_set_audit_log_format.
+	auditFormat atomic.Value // string
+
+	// blockPage overrides the default block page; see
+	// coraza_set_block_page_template.
+	blockPage atomic.Value // *template.Template
+
+	// captureOnBlock keeps body bytes for forensics; see
+	// coraza_set_capture_on_block.
+	captureOnBlock atomic.Bool
+
+	// blockStatus holds the deny status overrides; see
+	// coraza_set_block_status. blockStatusMu serializes updates.
+	blockStatus   atomic.Pointer[blockStatuses]
+	blockStatusMu sync.Mutex
+
+	// actionOverrides remaps disruptive actions; see
+	// coraza_set_action_override. actionOverridesMu serializes updates.
+	actionOverrides   atomic.Pointer[map[string]actionOverride]
+	actionOverridesMu sync.Mutex
+
+	// argLimits caps the arguments parsed per request; see
+	// coraza_set_arg_limits.
+	argLimits atomic.Pointer[argLimits]
+
+	// allowedMethods is the HTTP method allowlist, if any; see
+	// coraza_set_allowed_methods.
+	allowedMethods atomic.Pointer[map[string]bool]
+
+	// headerLimits caps the request headers per request; see
+	// coraza_set_header_limits.
+	headerLimits atomic.Pointer[headerLimits]
+
+	// trustedHops is the number of trusted proxies; see
+	// coraza_set_trusted_hops.
+	trustedHops atomic.Int32
+
+	// maskedHeaders are the lowercase names of the headers whose values are
+	// masked in reports; see coraza_set_masked_headers.
+	maskedHeaders atomic.Pointer[map[string]bool]
+
+	// classificationVar is the TX variable holding the client
+	// classification, if not the default; see
+	// coraza_set_client_classification_var.
+	classificationVar atomic.Pointer[string]
+
+	// geoDB is the database used by @geoLookup; see coraza_set_geoip_db.
+	geoDB atomic.Pointer[maxminddb.Reader]
+
+	// collections are the WAF's persistent collections, such as SESSION.
+	collections *collectionStore
+}
 
+// newWAF builds a WAF from cfg, recording the error and returning nil if the
+// configuration is invalid.
+func newWAF(cfg coraza.WAFConfig, sources []string) *wafState {
+	waf, warnings, err := buildWAF(cfg)
+	if err != nil {
+		setLastError(err)
+		return nil
+	}
+	ws := &wafState{cfg: cfg, sources: sources, warnings: warnings, createdAt: time.Now(), collections: newCollectionStore()}
+	ws.waf.Store(waf)
+	ws.auditFormat.Store(auditFormatNative)
+	ws.maskedHeaders.Store(&defaultMaskedHeaders)
+	return ws
+}
+
+// buildWAF builds a WAF from cfg with the bridge's loggers, returning the
+// warnings logged while loading it.
+func buildWAF(cfg coraza.WAFConfig) (coraza.WAF, []string, error) {
+	rec := &warningRecorder{}
+	waf, err := coraza.NewWAF(cfg.WithDebugLogger(rec.logger()).WithErrorCallback(notifyMatch))
+	return waf, rec.stop(), err
+}
+
+// engine returns the WAF new transactions are created from.
+func (ws *wafState) engine() coraza.WAF {
+	return ws.waf.Load().(coraza.WAF)
+}
+
+func registerWAF(ws *wafState) uint64 {
 	id := atomic.AddUint64(&wafCounter, 1)
-	wafInstances.Store(id, waf)
-	return C.uint64_t(id)
+	ws.id = id
+	ws.configMu.Lock()
+	ws.registerEngine(ws.engine())
+	ws.configMu.Unlock()
+	wafInstances.Store(id, ws)
+	activeWAFs.Add(1)
+	return id
+}
+
+func lookupWAF(wafID C.uint64_t) (*wafState, bool) {
+	val, ok := wafInstances.Load(uint64(wafID))
+	if !ok {
+		return nil, false
+	}
+	return val.(*wafState), true
 }
 
 //export coraza_new_transaction
 func coraza_new_transaction(wafID C.uint64_t) C.uint64_t {
-	val, ok := wafInstances.Load(uint64(wafID))
+	ws, ok := lookupWAF(wafID)
 	if !ok {
 		return 0
 	}
-	waf := val.(coraza.WAF)
 
-	tx := waf.NewTransaction()
+	return C.uint64_t(newTransaction(ws))
+}
+
+// txState is the bridge-side record kept for each live transaction.
+type txState struct {
+	tx types.Transaction
+
+	// waf is the WAF the transaction was created from; waf.id is its handle.
+	waf *wafState
+
+	// createdAt is when the handle was created.
+	createdAt time.Time
+
+	// uriDecoded is set when the host passes an already percent-decoded URI.
+	uriDecoded bool
+
+	// responseEncoding is the Content-Encoding the response body arrives in;
+	// it is decompressed before being handed to Coraza. inflater decodes the
+	// chunks as they arrive, started by the first one.
+	responseEncoding string
+	inflater         *inflater
+
+	// responseStreaming enables per-event inspection of long-lived response
+	// bodies. streamPending holds the incomplete trailing event and
+	// streamRules the rule group run on each event, built on first use.
+	responseStreaming bool
+	streamPending     []byte
+	streamRules       reflect.Value
+
+	// sessionID is the key of the SESSION collection record bound to this
+	// transaction, if any.
+	sessionID string
+
+	// context is host metadata echoed in the audit record; see
+	// coraza_set_tx_context.
+	context map[string]string
+
+	// requestLine is the request line and the problems found in it; see
+	// coraza_get_uri_info_json.
+	requestLine *uriInfo
+
+	// timings accumulates the time spent inside the WAF, per phase, and
+	// phase is the latest phase run to completion.
+	timings [types.PhaseLogging + 1]time.Duration
+	phase   types.RulePhase
+
+	// interruptions lists every interruption raised, in order, with the phase
+	// that raised it. lastInterruption is the most recently recorded one.
+	interruptions    []phaseInterruption
+	lastInterruption *types.Interruption
+
+	// capturedRequest and capturedResponse hold the raw body bytes while the
+	// WAF captures bodies on block, or the request body regardless when
+	// captureRequestBody is set; see coraza_set_capture_request_body.
+	capturedRequest    bytes.Buffer
+	capturedResponse   bytes.Buffer
+	captureRequestBody bool
+
+	// logged is set once the logging phase has run.
+	logged bool
+
+	// upgraded is set when the response switched protocols, which ends the
+	// HTTP exchange without a response body.
+	upgraded bool
+
+	// requestBodyStarted is set once request body bytes have been written.
+	// wafRequestBodyLimit is the WAF's limit, kept while a per-transaction
+	// override is in effect so it can be restored.
+	requestBodyStarted  bool
+	wafRequestBodyLimit int64
+
+	// responseBodyStarted and wafResponseBodyLimit are their response
+	// counterparts.
+	responseBodyStarted  bool
+	wafResponseBodyLimit int64
+
+	// args counts the arguments seen against the WAF's argument limits.
+	args argCounter
+
+	// headers and responseHeaders count the request and response headers
+	// added against the WAF's header limits.
+	headers         headerCounter
+	responseHeaders headerCounter
+
+	// txID is the transaction's handle.
+	txID uint64
+
+	// matchCallback is the C function notified of rule matches, if any; see
+	// coraza_set_match_callback.
+	matchCallback *byte
+
+	// outboundThreshold overrides TX:outbound_anomaly_score_threshold for
+	// the response phases when not 0.
+	outboundThreshold int
+
+	// deadline, if set, is when phase processing must give up; see
+	// coraza_set_transaction_deadline.
+	deadline time.Time
+
+	// runaway is set when a phase exceeded the processing timeout and
+	// receives its result once Coraza returns. Until then the handle is
+	// unusable; see coraza_set_processing_timeout.
+	runaway chan C.int
+}
+
+type phaseInterruption struct {
+	Phase  int    `json:"phase"`
+	Status int    `json:"status"`
+	Action string `json:"action"`
+	RuleID int    `json:"rule_id"`
+	// Step is the part of coraza_process_request or
+	// coraza_process_response that interrupted.
+	Step string `json:"step,omitempty"`
+}
+
+// interrupted records an interruption observed while processing phase and
+// returns the status to hand back to the caller. Coraza keeps returning the
+// same interruption from later phases; it is only recorded once.
+func (st *txState) interrupted(phase types.RulePhase, it *types.Interruption) C.int {
+	if it != st.lastInterruption {
+		st.lastInterruption = it
+		st.waf.overrideAction(it)
+		interruptionsTotal.Add(1)
+		st.waf.interruptionsTotal.Add(1)
+		if !st.waf.passThrough() {
+			countBlock(it.Action)
+		}
+		st.interruptions = append(st.interruptions, phaseInterruption{
+			Phase:  int(phase),
+			Status: st.status(it),
+			Action: it.Action,
+			RuleID: it.RuleID,
+		})
+	}
+	if st.waf.passThrough() {
+		return 0
+	}
+	return C.int(st.status(it))
+}
+
+func (st *txState) track(phase types.RulePhase, start time.Time) {
+	st.timings[phase] += time.Since(start)
+}
+
+// complete is track for a call that runs the phase to its end.
+func (st *txState) complete(phase types.RulePhase, start time.Time) {
+	st.track(phase, start)
+	if phase > st.phase {
+		st.phase = phase
+	}
+}
+
+func (st *txState) elapsed() time.Duration {
+	var total time.Duration
+	for _, d := range st.timings {
+		total += d
+	}
+	return total
+}
+
+func lookupTxState(txID C.uint64_t) (*txState, bool) {
+	val, ok := txInstances.Load(uint64(txID))
+	if !ok || val.(*txState).runaway != nil {
+		return nil, false
+	}
+	return val.(*txState), true
+}
+
+func lookupTx(txID C.uint64_t) (types.Transaction, bool) {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil, false
+	}
+	return st.tx, true
+}
+
+func newTransaction(ws *wafState) uint64 {
+	st := &txState{tx: ws.engine().NewTransaction(), waf: ws, createdAt: time.Now()}
 	id := atomic.AddUint64(&txCounter, 1)
-	txInstances.Store(id, tx)
-	return C.uint64_t(id)
+	st.txID = id
+	txInstances.Store(id, st)
+	activeTransactions.Add(1)
+	transactionsTotal.Add(1)
+	ws.activeTransactions.Add(1)
+	ws.transactionsTotal.Add(1)
+	return id
 }
 
 //export coraza_process_request_headers
 func coraza_process_request_headers(txID C.uint64_t, method, uri, protocol, headersJSON *C.char) C.int {
-	val, ok := txInstances.Load(uint64(txID))
+	st, ok := lookupTxState(txID)
 	if !ok {
 		return -1
 	}
-	tx := val.(types.Transaction)
-
-	methodStr := C.GoString(method)
-	uriStr := C.GoString(uri)
-	protocolStr := C.GoString(protocol)
-
-	tx.ProcessURI(uriStr, methodStr, protocolStr)
+	start := time.Now()
 
 	headersStr := C.GoString(headersJSON)
 	var headers [][2]string
-	if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-		for _, h := range headers {
-			tx.AddRequestHeader(h[0], h[1])
-		}
+	if err := json.Unmarshal([]byte(headersStr), &headers); err != nil {
+		headers = nil
+	}
+	m, u, p := C.GoString(method), C.GoString(uri), C.GoString(protocol)
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseRequestHeaders, start)
+		return st.processRequestHeaders(m, u, p, headers)
+	})
+}
+
+func (st *txState) processRequestHeaders(method, uri, protocol string, headers [][2]string) C.int {
+	st.processURI(method, uri, protocol)
+	st.processHeaders(method, headers)
+	if it := st.tx.Interruption(); it != nil {
+		return st.interrupted(types.PhaseRequestHeaders, it)
+	}
+	return 0
+}
+
+// processURI sets the request line variables from the URI.
+func (st *txState) processURI(method, uri, protocol string) {
+	tx := st.tx
+	st.checkRequestLine(method, uri, protocol)
+	uri = st.limitQueryArgs(uri)
+	switch {
+	case isAuthorityForm(method, uri):
+		processAuthorityURI(tx, uri, method, protocol)
+	case st.uriDecoded:
+		processDecodedURI(tx, uri, method, protocol)
+	default:
+		tx.ProcessURI(uri, method, protocol)
+	}
+}
+
+// processHeaders adds the request headers and runs phase 1.
+func (st *txState) processHeaders(method string, headers [][2]string) {
+	tx := st.tx
+	for _, h := range headers {
+		st.addRequestHeader(h[0], h[1])
 	}
 
 	tx.ProcessRequestHeaders()
+	if !tx.IsInterrupted() {
+		st.checkMethod(method)
+	}
+}
 
-	if it := tx.Interruption(); it != nil {
-		return C.int(it.Status)
+// coraza_process_request runs coraza_process_connection and
+// coraza_process_request_headers in a single call: it sets the connection
+// variables, processes the URI and adds the headers, then evaluates phase 1.
+// Returns the interruption status, 0 to continue, or -1 on error, like
+// coraza_process_request_headers. The step that interrupted, "connection",
+// "uri" or "headers", is recorded as "step" in
+// coraza_get_all_interventions_json.
+//
+//export coraza_process_request
+func coraza_process_request(txID C.uint64_t, clientIP *C.char, clientPort C.int, serverIP *C.char, serverPort C.int, method, uri, protocol, headersJSON *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
 	}
-	return 0
+	start := time.Now()
+
+	var headers [][2]string
+	if err := json.Unmarshal([]byte(C.GoString(headersJSON)), &headers); err != nil {
+		headers = nil
+	}
+	cip, sip := C.GoString(clientIP), C.GoString(serverIP)
+	m, u, p := C.GoString(method), C.GoString(uri), C.GoString(protocol)
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseRequestHeaders, start)
+		tx := st.tx
+		steps := []struct {
+			name string
+			run  func()
+		}{
+			{"connection", func() { tx.ProcessConnection(cip, int(clientPort), sip, int(serverPort)) }},
+			{"uri", func() { st.processURI(m, u, p) }},
+			{"headers", func() { st.processHeaders(m, headers) }},
+		}
+		for _, step := range steps {
+			step.run()
+			if it := tx.Interruption(); it != nil {
+				n := len(st.interruptions)
+				status := st.interrupted(types.PhaseRequestHeaders, it)
+				if len(st.interruptions) > n {
+					st.interruptions[n].Step = step.name
+				}
+				return status
+			}
+		}
+		return 0
+	})
 }
 
 //export coraza_process_request_body
 func coraza_process_request_body(txID C.uint64_t, body unsafe.Pointer, bodyLen C.int) C.int {
-	val, ok := txInstances.Load(uint64(txID))
+	st, ok := lookupTxState(txID)
 	if !ok {
 		return -1
 	}
-	tx := val.(types.Transaction)
+	start := time.Now()
 
+	var buf []byte
 	if bodyLen > 0 && body != nil {
-		buf := C.GoBytes(body, bodyLen)
-		if it, _, err := tx.WriteRequestBody(buf); it != nil {
-			return C.int(it.Status)
-		} else if err != nil {
-			return -1
+		buf = C.GoBytes(body, bodyLen)
+	}
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseRequestBody, start)
+		return st.processRequestBody(buf)
+	})
+}
+
+func (st *txState) processRequestBody(buf []byte) C.int {
+	if len(buf) > 0 {
+		if status := st.writeRequestBody(buf); status != 0 {
+			return status
 		}
 	}
 
-	if it, err := tx.ProcessRequestBody(); it != nil {
-		return C.int(it.Status)
+	if it, err := st.tx.ProcessRequestBody(); it != nil {
+		return st.interrupted(types.PhaseRequestBody, it)
 	} else if err != nil {
-		return -1
+		return inspectionError()
 	}
 
 	return 0
 }
 
+// coraza_process_response_headers runs the response headers phase. A 101
+// Switching Protocols response (e.g. a WebSocket upgrade) has no HTTP body, so
+// the response body phase is completed here as well and later response body
+// calls return 0 without inspecting the upgraded connection's traffic.
+//
 //export coraza_process_response_headers
 func coraza_process_response_headers(txID C.uint64_t, statusCode C.int, headersJSON *C.char) C.int {
-	val, ok := txInstances.Load(uint64(txID))
+	st, ok := lookupTxState(txID)
 	if !ok {
 		return -1
 	}
-	tx := val.(types.Transaction)
+	start := time.Now()
 
 	headersStr := C.GoString(headersJSON)
 	var headers [][2]string
-	if err := json.Unmarshal([]byte(headersStr), &headers); err == nil {
-		for _, h := range headers {
-			tx.AddResponseHeader(h[0], h[1])
+	if err := json.Unmarshal([]byte(headersStr), &headers); err != nil {
+		headers = nil
+	}
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseResponseHeaders, start)
+		return st.processResponseHeaders(int(statusCode), headers)
+	})
+}
+
+func (st *txState) processResponseHeaders(statusCode int, headers [][2]string) C.int {
+	tx := st.tx
+	if st.outboundThreshold > 0 {
+		if vars, ok := txVariables(tx); ok {
+			vars.TX().Set("outbound_anomaly_score_threshold", []string{strconv.Itoa(st.outboundThreshold)})
 		}
 	}
+	for _, h := range headers {
+		st.addResponseHeader(h[0], h[1])
+	}
 
-	tx.ProcessResponseHeaders(int(statusCode), "HTTP/1.1")
+	tx.ProcessResponseHeaders(statusCode, "HTTP/1.1")
 
 	if it := tx.Interruption(); it != nil {
-		return C.int(it.Status)
+		return st.interrupted(types.PhaseResponseHeaders, it)
+	}
+
+	if statusCode == http.StatusSwitchingProtocols {
+		st.upgraded = true
+		start := time.Now()
+		_, err := tx.ProcessResponseBody()
+		st.complete(types.PhaseResponseBody, start)
+		st.releaseCapture()
+		if it := tx.Interruption(); it != nil {
+			return st.interrupted(types.PhaseResponseBody, it)
+		} else if err != nil {
+			return inspectionError()
+		}
 	}
 	return 0
 }
 
 //export coraza_process_response_body
 func coraza_process_response_body(txID C.uint64_t, body unsafe.Pointer, bodyLen C.int) C.int {
-	val, ok := txInstances.Load(uint64(txID))
+	st, ok := lookupTxState(txID)
 	if !ok {
 		return -1
 	}
-	tx := val.(types.Transaction)
+	if st.upgraded {
+		return 0
+	}
+	start := time.Now()
 
+	var buf []byte
 	if bodyLen > 0 && body != nil {
-		buf := C.GoBytes(body, bodyLen)
+		buf = C.GoBytes(body, bodyLen)
+	}
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseResponseBody, start)
+		defer st.releaseCapture()
+		return st.processResponseBody(buf)
+	})
+}
+
+func (st *txState) processResponseBody(buf []byte) C.int {
+	tx := st.tx
+	st.responseBodyStarted = true
+	st.captureResponse(buf)
+
+	if st.responseStreaming {
+		return st.writeResponseStream(buf, true)
+	}
+
+	if st.responseEncoding != "" && (len(buf) > 0 || st.inflater != nil) {
+		st.inflate(buf)
+		var err error
+		buf, err = st.inflater.finish()
+		st.inflater = nil
+		if err != nil {
+			return inspectionError()
+		}
+	}
+
+	if len(buf) > 0 {
 		if it, _, err := tx.WriteResponseBody(buf); it != nil {
-			return C.int(it.Status)
+			return st.interrupted(types.PhaseResponseBody, it)
 		} else if err != nil {
-			return -1
+			return inspectionError()
 		}
 	}
 
 	if it, err := tx.ProcessResponseBody(); it != nil {
-		return C.int(it.Status)
+		return st.interrupted(types.PhaseResponseBody, it)
 	} else if err != nil {
-		return -1
+		return inspectionError()
 	}
 
 	return 0
 }
 
+// coraza_process_response runs coraza_process_response_headers and
+// coraza_process_response_body in a single call, for a response the host
+// has in full, e.g. one served from cache: it adds the headers and evaluates
+// phase 3, then, unless that interrupted or the response switched protocols,
+// inspects the body and evaluates phase 4. Streamed responses still need the
+// separate functions. Returns the first interruption status, 0 to continue,
+// or -1 on error, like the functions it replaces. The step that interrupted,
+// "headers" or "body", is recorded as "step" in
+// coraza_get_all_interventions_json.
+//
+//export coraza_process_response
+func coraza_process_response(txID C.uint64_t, statusCode C.int, headersJSON *C.char, body unsafe.Pointer, bodyLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+
+	var headers [][2]string
+	if err := json.Unmarshal([]byte(C.GoString(headersJSON)), &headers); err != nil {
+		headers = nil
+	}
+	var buf []byte
+	if bodyLen > 0 && body != nil {
+		buf = C.GoBytes(body, bodyLen)
+	}
+	return st.guard(func() C.int {
+		defer st.releaseCapture()
+		steps := []struct {
+			name  string
+			phase types.RulePhase
+			run   func() C.int
+		}{
+			{"headers", types.PhaseResponseHeaders, func() C.int { return st.processResponseHeaders(int(statusCode), headers) }},
+			{"body", types.PhaseResponseBody, func() C.int { return st.processResponseBody(buf) }},
+		}
+		for _, step := range steps {
+			if step.phase == types.PhaseResponseBody && st.upgraded {
+				return 0
+			}
+			n := len(st.interruptions)
+			start := time.Now()
+			status := step.run()
+			st.complete(step.phase, start)
+			for i := n; i < len(st.interruptions); i++ {
+				st.interruptions[i].Step = step.name
+			}
+			if status != 0 {
+				return status
+			}
+		}
+		return 0
+	})
+}
+
+// unexportedField returns the named field of the addressable struct v, made
+// settable.
+func unexportedField(v reflect.Value, name string) (reflect.Value, bool) {
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanAddr() {
+		return reflect.Value{}, false
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem(), true
+}
+
+// setSingle overwrites a single-valued variable. The collections handed out
+// by Coraza are writable, but the public interface is read-only.
+func setSingle(col collection.Single, value string) bool {
+	w, ok := col.(interface{ Set(string) })
+	if ok {
+		w.Set(value)
+	}
+	return ok
+}
+
+// coraza_intervention_status returns the status to block the transaction
+// with, or 0 if it was not interrupted, the WAF is a dry run or its
+// enforcement is off, or for an unknown handle.
+//
 //export coraza_intervention_status
 func coraza_intervention_status(txID C.uint64_t) C.int {
-	val, ok := txInstances.Load(uint64(txID))
+	st, ok := lookupTxState(txID)
 	if !ok {
 		return 0
 	}
-	tx := val.(types.Transaction)
 
-	if it := tx.Interruption(); it != nil {
-		return C.int(it.Status)
+	if it := st.enforcedInterruption(); it != nil {
+		return C.int(st.status(it))
 	}
 	return 0
 }
 
 //export coraza_intervention_url
 func coraza_intervention_url(txID C.uint64_t) *C.char {
-	val, ok := txInstances.Load(uint64(txID))
+	tx, ok := lookupTx(txID)
 	if !ok {
 		return nil
 	}
-	tx := val.(types.Transaction)
 
 	it := tx.Interruption()
 	if it == nil || it.Action != "redirect" {
@@ -195,13 +820,83 @@ func coraza_free_transaction(txID C.uint64_t) {
 	if !ok {
 		return
 	}
-	tx := val.(types.Transaction)
-	tx.Close()
+	val.(*txState).close()
+}
+
+// close saves the transaction's persistent state and releases it to Coraza.
+// The handle must already have been removed from txInstances.
+func (st *txState) close() {
+	if ch := st.runaway; ch != nil {
+		// Coraza is still evaluating; release it once it returns.
+		st.runaway = nil
+		go func() {
+			<-ch
+			st.close()
+		}()
+		return
+	}
+	activeTransactions.Add(-1)
+	st.waf.activeTransactions.Add(-1)
+	if st.matchCallback != nil {
+		matchListeners.Delete(st.tx.ID())
+	}
+	st.saveSession()
+	if st.inflater != nil {
+		st.inflater.abort()
+	}
+	if st.wafRequestBodyLimit != 0 {
+		// Coraza pools transactions along with their body buffers.
+		setRequestBodyLimit(st.tx, st.wafRequestBodyLimit)
+	}
+	if st.wafResponseBodyLimit != 0 {
+		setResponseBodyLimit(st.tx, st.wafResponseBodyLimit)
+	}
+	st.tx.Close()
 }
 
 //export coraza_free_waf
 func coraza_free_waf(wafID C.uint64_t) {
-	wafInstances.Delete(uint64(wafID))
+	if val, ok := wafInstances.LoadAndDelete(uint64(wafID)); ok {
+		activeWAFs.Add(-1)
+		ws := val.(*wafState)
+		ws.unregisterEngine()
+		ws.collections.clear()
+	}
+}
+
+// txVariables exposes the variable collections of a transaction. Coraza only
+// publishes them through the plugin API, which every transaction implements.
+func txVariables(tx types.Transaction) (plugintypes.TransactionVariables, bool) {
+	state, ok := tx.(plugintypes.TransactionState)
+	if !ok {
+		return nil, false
+	}
+	return state.Variables(), true
+}
+
+// txIntVar returns the first of the given TX collection variables that holds
+// an integer.
+func txIntVar(tx types.Transaction, keys ...string) (int, bool) {
+	vars, ok := txVariables(tx)
+	if !ok {
+		return 0, false
+	}
+	for _, key := range keys {
+		if v := vars.TX().Get(key); len(v) > 0 {
+			if n, err := strconv.Atoi(v[0]); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func jsonCString(v any) *C.char {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return C.CString(string(b))
 }
 
 func main() {}
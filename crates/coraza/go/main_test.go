@@ -0,0 +1,365 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// call invokes an exported bridge function from Go, as a host would through
+// the C ABI. Test files cannot use cgo, so arguments are converted by
+// reflection: integers to the C integer type of the parameter, strings to
+// NUL-terminated *C.char, unsafe.Pointer to the C pointer type of the
+// parameter and nil to the zero value. It returns the result, or the zero
+// Value for a function without one.
+func call(fn any, args ...any) reflect.Value {
+	f := reflect.ValueOf(fn)
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		pt := f.Type().In(i)
+		switch a := arg.(type) {
+		case nil:
+			in[i] = reflect.Zero(pt)
+		case string:
+			b := append([]byte(a), 0)
+			in[i] = reflect.NewAt(pt.Elem(), unsafe.Pointer(&b[0]))
+		case unsafe.Pointer:
+			if pt.Kind() == reflect.Pointer {
+				in[i] = reflect.NewAt(pt.Elem(), a)
+			} else {
+				in[i] = reflect.ValueOf(a)
+			}
+		default:
+			in[i] = reflect.ValueOf(arg).Convert(pt)
+		}
+	}
+	out := f.Call(in)
+	if len(out) == 0 {
+		return reflect.Value{}
+	}
+	return out[0]
+}
+
+// callInt is call for a function returning a C integer.
+func callInt(fn any, args ...any) int64 {
+	v := call(fn, args...)
+	if v.CanUint() {
+		return int64(v.Uint())
+	}
+	return v.Int()
+}
+
+// callString is call for a function returning a C string, reporting false
+// for nil. The string is not freed, as test files cannot call C.free.
+func callString(fn any, args ...any) (string, bool) {
+	v := call(fn, args...)
+	if v.IsNil() {
+		return "", false
+	}
+	var b []byte
+	for p := v.UnsafePointer(); *(*byte)(p) != 0; p = unsafe.Add(p, 1) {
+		b = append(b, *(*byte)(p))
+	}
+	return string(b), true
+}
+
+// newTestWAF creates a WAF from directives with coraza_new_waf and frees it
+// when the test ends.
+func newTestWAF(t testing.TB, directives string) uint64 {
+	t.Helper()
+	waf := uint64(callInt(coraza_new_waf, directives))
+	if waf == 0 {
+		t.Fatalf("coraza_new_waf: %s", lastError)
+	}
+	t.Cleanup(func() { call(coraza_free_waf, waf) })
+	return waf
+}
+
+// collectionValue returns the first value under key of a transaction
+// variable, matching the key case-insensitively; single-valued variables
+// have the empty key.
+func collectionValue(t *testing.T, tx uint64, name, key string) string {
+	t.Helper()
+	val, ok := txInstances.Load(tx)
+	if !ok {
+		t.Fatalf("unknown transaction %d", tx)
+	}
+	vars, ok := txVariables(val.(*txState).tx)
+	if !ok {
+		t.Fatal("transaction variables are not reachable")
+	}
+	v, err := variables.Parse(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value string
+	vars.All(func(rv variables.RuleVariable, col collection.Collection) bool {
+		if rv != v {
+			return true
+		}
+		for _, md := range col.FindAll() {
+			if strings.EqualFold(md.Key(), key) {
+				value = md.Value()
+				break
+			}
+		}
+		return false
+	})
+	return value
+}
+
+func TestParseRawHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want [][2]string
+	}{
+		{"empty", "", nil},
+		{"single", "Host: example.com\r\n", [][2]string{{"Host", "example.com"}}},
+		{"bare LF", "Host: a\nAccept: */*\n", [][2]string{{"Host", "a"}, {"Accept", "*/*"}}},
+		{"no trailing newline", "Host: a", [][2]string{{"Host", "a"}}},
+		{"value whitespace", "X-A:  \t b c \t\r\n", [][2]string{{"X-A", "b c"}}},
+		{"empty value", "X-Empty:\r\nX-B: 1\r\n", [][2]string{{"X-Empty", ""}, {"X-B", "1"}}},
+		{"colon in value", "Referer: http://a/b\r\n", [][2]string{{"Referer", "http://a/b"}}},
+		{
+			"repeated names keep order",
+			"Cookie: a=1\r\nX-B: 2\r\ncookie: c=3\r\n",
+			[][2]string{{"Cookie", "a=1"}, {"X-B", "2"}, {"cookie", "c=3"}},
+		},
+		{
+			"obs-fold",
+			"X-Long: one\r\n two\r\n\tthree\r\nX-B: 1\r\n",
+			[][2]string{{"X-Long", "one two three"}, {"X-B", "1"}},
+		},
+		{"obs-fold onto empty value", "X-A:\r\n  b\r\n", [][2]string{{"X-A", "b"}}},
+		{"blank continuation", "X-A: a\r\n \r\n", [][2]string{{"X-A", "a"}}},
+		{"leading continuation dropped", " orphan\r\nX-A: a\r\n", [][2]string{{"X-A", "a"}}},
+		{"line without colon skipped", "X-A: a\r\nbogus line\r\nX-B: b\r\n", [][2]string{{"X-A", "a"}, {"X-B", "b"}}},
+		{"stops at blank line", "X-A: a\r\n\r\nX-B: b\r\n", [][2]string{{"X-A", "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRawHeaders([]byte(tt.raw)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRawHeaders(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessRequestHeadersRaw(t *testing.T) {
+	raw := []byte("Host: example.com\r\nX-Long: one\r\n two\r\nX-Dup: a\r\nX-Dup: b\r\n\r\n")
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRule REQUEST_HEADERS:X-Long "@streq one two" "id:1,phase:1,deny,status:403"`)
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+
+	if got := callInt(coraza_process_request_headers_raw, tx, "GET", "/", "HTTP/1.1", unsafe.Pointer(&raw[0]), len(raw)); got != 403 {
+		t.Errorf("status = %d, want 403 for the folded header", got)
+	}
+	val, _ := txInstances.Load(tx)
+	vars, _ := txVariables(val.(*txState).tx)
+	if got := vars.RequestHeaders().Get("x-dup"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("REQUEST_HEADERS:X-Dup = %q, want both values", got)
+	}
+}
+
+func TestNewTransactions(t *testing.T) {
+	waf := newTestWAF(t, "SecRuleEngine On")
+	ids := make([]uint64, 3)
+	if got := callInt(coraza_new_transactions, waf, len(ids), &ids[0]); got != 3 {
+		t.Fatalf("coraza_new_transactions = %d, want 3", got)
+	}
+	for _, id := range ids {
+		if _, ok := txInstances.Load(id); !ok {
+			t.Errorf("transaction %d is not registered", id)
+		}
+		call(coraza_free_transaction, id)
+	}
+
+	tests := []struct {
+		name string
+		waf  uint64
+		n    int
+		out  *uint64
+	}{
+		{"unknown WAF", 0, 1, &ids[0]},
+		{"zero n", waf, 0, &ids[0]},
+		{"negative n", waf, -1, &ids[0]},
+		{"nil out", waf, 1, nil},
+	}
+	for _, tt := range tests {
+		ids[0] = 0
+		if got := callInt(coraza_new_transactions, tt.waf, tt.n, tt.out); got != -1 || ids[0] != 0 {
+			t.Errorf("%s: got %d with out[0] = %d, want -1 and out untouched", tt.name, got, ids[0])
+		}
+	}
+}
+
+// requestHeaders runs the request headers phase of a form POST.
+func requestHeaders(tx uint64) {
+	callInt(coraza_process_request_headers, tx, "POST", "/", "HTTP/1.1",
+		`[["Content-Type","application/x-www-form-urlencoded"]]`)
+}
+
+func TestSetEngineMode(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRule ARGS:attack "@rx ." "id:1,phase:1,deny,status:403,log"`)
+	// run sends a malicious request through tx, returning the status and
+	// the number of rules that matched.
+	run := func(tx uint64) (int64, int64) {
+		defer call(coraza_free_transaction, tx)
+		status := callInt(coraza_process_request_headers, tx, "GET", "/?attack=1", "HTTP/1.1", "[]")
+		return status, callInt(coraza_matched_rule_count, tx)
+	}
+	newTx := func() uint64 { return uint64(callInt(coraza_new_transaction, waf)) }
+	setMode := func(mode any) {
+		t.Helper()
+		if got := callInt(coraza_set_engine_mode, waf, mode); got != 0 {
+			t.Fatalf("coraza_set_engine_mode(%v) = %d: %s", mode, got, lastError)
+		}
+	}
+	check := func(name string, tx uint64, wantStatus, wantMatched int64) {
+		t.Helper()
+		if status, matched := run(tx); status != wantStatus || matched != wantMatched {
+			t.Errorf("%s: status %d with %d matched rules, want %d with %d", name, status, matched, wantStatus, wantMatched)
+		}
+	}
+
+	before := newTx()
+	setMode("DetectionOnly")
+	check("detection only", newTx(), 0, 1)
+	check("created before the change", before, 403, 1)
+
+	before = newTx()
+	setMode("off")
+	check("off", newTx(), 0, 0)
+	check("created in detection only", before, 0, 1)
+
+	setMode("DetectionOnly")
+	if got := callInt(coraza_add_rule, waf, `SecRule ARGS:other "@rx ." "id:2,phase:1,deny,status:403"`, nil); got != 0 {
+		t.Fatalf("coraza_add_rule = %d: %s", got, lastError)
+	}
+	check("after coraza_add_rule", newTx(), 0, 1)
+
+	setMode(nil)
+	check("configured mode", newTx(), 403, 1)
+
+	if got := callInt(coraza_set_engine_mode, waf, "Bogus"); got != -1 {
+		t.Errorf("coraza_set_engine_mode(Bogus) = %d, want -1", got)
+	}
+	check("after an invalid mode", newTx(), 403, 1)
+}
+
+func TestSetEngineModeDryRun(t *testing.T) {
+	waf := uint64(callInt(coraza_new_waf_dryrun, `SecRule ARGS:attack "@rx ." "id:1,phase:1,deny,status:403,log"`))
+	if waf == 0 {
+		t.Fatalf("coraza_new_waf_dryrun: %s", lastError)
+	}
+	defer call(coraza_free_waf, waf)
+
+	if got := callInt(coraza_set_engine_mode, waf, "Off"); got != -1 {
+		t.Fatalf("coraza_set_engine_mode = %d, want -1", got)
+	}
+	if !strings.Contains(lastError, "dry-run") {
+		t.Errorf("last error = %q, want the dry-run WAF reported", lastError)
+	}
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	callInt(coraza_process_request_headers, tx, "GET", "/?attack=1", "HTTP/1.1", "[]")
+	if got := callInt(coraza_matched_rule_count, tx); got != 1 {
+		t.Errorf("%d rules matched, want the dry run still evaluating", got)
+	}
+}
+
+func TestEnforcementOff(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRule ARGS:attack "@rx ." "id:1,phase:1,deny,status:403,log"`)
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	callInt(coraza_set_enforcement, waf, 0)
+	if got := callInt(coraza_process_request_headers, tx, "GET", "/?attack=1", "HTTP/1.1", "[]"); got != 0 {
+		t.Fatalf("status = %d, want 0 with enforcement off", got)
+	}
+
+	// check compares what the getters report with the status to block with.
+	check := func(want int64) {
+		t.Helper()
+		if got := callInt(coraza_intervention_status, tx); got != want {
+			t.Errorf("coraza_intervention_status = %d, want %d", got, want)
+		}
+		if _, ok := callString(coraza_get_block_response_json, tx); ok != (want != 0) {
+			t.Errorf("coraza_get_block_response_json non-nil = %v, want %v", ok, want != 0)
+		}
+		if _, ok := callString(coraza_get_block_page_html, tx); ok != (want != 0) {
+			t.Errorf("coraza_get_block_page_html non-nil = %v, want %v", ok, want != 0)
+		}
+		var res [4]int32
+		callInt(coraza_result_packed, tx, unsafe.Pointer(&res[0]), len(res)*4)
+		if int64(res[0]) != want {
+			t.Errorf("coraza_result_packed status = %d, want %d", res[0], want)
+		}
+		if _, ok := callString(coraza_get_decisive_rule_json, tx); !ok {
+			t.Error("coraza_get_decisive_rule_json lost the recorded interruption")
+		}
+	}
+	check(0)
+	callInt(coraza_set_enforcement, waf, 1)
+	check(403)
+}
+
+func TestDefaultAction(t *testing.T) {
+	file := t.TempDir() + "/rules.conf"
+	if err := os.WriteFile(file, []byte("# blocking\nSecDefaultAction \\\n  \"phase:1,log,auditlog,deny,status:403\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	waf := newTestWAF(t, "SecRuleEngine On")
+	if got, _ := callString(coraza_default_action, waf); got != builtinDefaultAction {
+		t.Errorf("without SecDefaultAction: %q, want %q", got, builtinDefaultAction)
+	}
+
+	// A lower debug level must not hide later directives.
+	waf = newTestWAF(t, `SecDebugLogLevel 1
+SecDefaultAction "phase:2,log,auditlog,deny,status:403"
+# SecDefaultAction "phase:3,log,pass"`)
+	callInt(coraza_add_rule, waf, `SecDefaultAction "phase:4,log,auditlog,pass"`, nil)
+	want := "phase:2,log,auditlog,deny,status:403\nphase:4,log,auditlog,pass"
+	if got, _ := callString(coraza_default_action, waf); got != want {
+		t.Errorf("inline and added: %q, want %q", got, want)
+	}
+
+	waf = uint64(callInt(coraza_new_waf_from_files, `["`+file+`"]`))
+	if waf == 0 {
+		t.Fatalf("coraza_new_waf_from_files: %s", lastError)
+	}
+	defer call(coraza_free_waf, waf)
+	want = "phase:1,log,auditlog,deny,status:403"
+	if got, _ := callString(coraza_default_action, waf); got != want {
+		t.Errorf("from a file: %q, want %q", got, want)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk gone")
+}
+
+func TestCopyBodyReadError(t *testing.T) {
+	for _, mode := range []int32{failModeOpen, failModeClosed} {
+		failMode.Store(mode)
+		if got := copyBody(failingReader{}, 1024, nil, 0); got != -1 {
+			t.Errorf("fail mode %d: copyBody = %d, want -1", mode, got)
+		}
+		if !strings.Contains(lastError, "disk gone") {
+			t.Errorf("fail mode %d: last error = %q, want the read error", mode, lastError)
+		}
+	}
+	failMode.Store(failModeOpen)
+}
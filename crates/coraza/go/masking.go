@@ -0,0 +1,179 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// headerMask replaces the values of masked headers.
+const headerMask = "***"
+
+// defaultMaskedHeaders are masked until coraza_set_masked_headers is called.
+var defaultMaskedHeaders = map[string]bool{"authorization": true, "cookie": true}
+
+// coraza_set_masked_headers sets the headers, as a JSON array of
+// case-insensitive names, whose values the bridge reports as "***" for this
+// WAF's transactions: in the audit log, the collection, cookie, matched rule
+// and operator getters, and the match callback. Rules still see the real
+// values. Authorization and Cookie are masked by default; pass "[]" to mask
+// nothing or NULL to restore the default. Returns 0 on success or -1 for an
+// unknown WAF or invalid JSON.
+//
+//export coraza_set_masked_headers
+func coraza_set_masked_headers(wafID C.uint64_t, namesJSON *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	if namesJSON == nil {
+		ws.maskedHeaders.Store(&defaultMaskedHeaders)
+		return 0
+	}
+
+	var names []string
+	if err := json.Unmarshal([]byte(C.GoString(namesJSON)), &names); err != nil {
+		setLastError(fmt.Errorf("invalid header names JSON: %w", err))
+		return -1
+	}
+	masked := make(map[string]bool, len(names))
+	for _, n := range names {
+		masked[strings.ToLower(n)] = true
+	}
+	ws.maskedHeaders.Store(&masked)
+	return 0
+}
+
+// masksHeader reports whether the values of the named header are masked.
+func (ws *wafState) masksHeader(name string) bool {
+	masked := ws.maskedHeaders.Load()
+	return masked != nil && (*masked)[strings.ToLower(name)]
+}
+
+// masks reports whether the value of variable v under key is masked.
+func (ws *wafState) masks(v variables.RuleVariable, key string) bool {
+	switch v {
+	case variables.RequestHeaders, variables.ResponseHeaders:
+		return ws.masksHeader(key)
+	case variables.RequestCookies:
+		return ws.masksHeader("cookie")
+	}
+	return false
+}
+
+// maskMatched returns s with the masked values the rule matched replaced.
+func (st *txState) maskMatched(mr types.MatchedRule, s string) string {
+	for _, md := range mr.MatchedDatas() {
+		if v := md.Value(); v != "" && st.waf.masks(md.Variable(), md.Key()) {
+			s = strings.ReplaceAll(s, v, headerMask)
+		}
+	}
+	return s
+}
+
+// maskMessage returns s with the masked values matched by any of the
+// transaction's rules replaced. Audit record messages do not say which
+// matched data they came from, so all of them are considered.
+func (st *txState) maskMessage(s string) string {
+	for _, mr := range st.tx.MatchedRules() {
+		s = st.maskMatched(mr, s)
+	}
+	return s
+}
+
+// maskAuditMessages masks the message, msg and data of each message of a
+// native audit record decoded into fields.
+func (st *txState) maskAuditMessages(fields map[string]json.RawMessage) error {
+	raw, ok := fields["messages"]
+	if masked := st.waf.maskedHeaders.Load(); !ok || masked == nil || len(*masked) == 0 {
+		return nil
+	}
+	var msgs []map[string]json.RawMessage
+	err := json.Unmarshal(raw, &msgs)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := st.maskJSONString(msg, "message"); err != nil {
+			return err
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(msg["data"], &data); err != nil || data == nil {
+			continue
+		}
+		for _, k := range []string{"msg", "data"} {
+			if err := st.maskJSONString(data, k); err != nil {
+				return err
+			}
+		}
+		if msg["data"], err = json.Marshal(data); err != nil {
+			return err
+		}
+	}
+	fields["messages"], err = json.Marshal(msgs)
+	return err
+}
+
+// maskJSONString applies maskMessage to the string under key in fields, if
+// there is one.
+func (st *txState) maskJSONString(fields map[string]json.RawMessage, key string) error {
+	var s string
+	if err := json.Unmarshal(fields[key], &s); err != nil {
+		return nil
+	}
+	raw, err := json.Marshal(st.maskMessage(s))
+	fields[key] = raw
+	return err
+}
+
+// maskAuditHeaders masks the request and response header values of a native
+// audit record decoded into fields.
+func (ws *wafState) maskAuditHeaders(fields map[string]json.RawMessage) error {
+	raw, ok := fields["transaction"]
+	if masked := ws.maskedHeaders.Load(); !ok || masked == nil || len(*masked) == 0 {
+		return nil
+	}
+	var tx map[string]json.RawMessage
+	err := json.Unmarshal(raw, &tx)
+	if err != nil {
+		return err
+	}
+	for _, part := range []string{"request", "response"} {
+		raw, ok := tx[part]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return err
+		}
+		var headers map[string][]string
+		if err := json.Unmarshal(msg["headers"], &headers); err != nil || headers == nil {
+			continue
+		}
+		for name, values := range headers {
+			if ws.masksHeader(name) {
+				for i := range values {
+					values[i] = headerMask
+				}
+			}
+		}
+		if msg["headers"], err = json.Marshal(headers); err != nil {
+			return err
+		}
+		if tx[part], err = json.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	fields["transaction"], err = json.Marshal(tx)
+	return err
+}
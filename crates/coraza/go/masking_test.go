@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMaskedAuditLog checks that a masked header value matched by a rule,
+// and echoed in its message and logdata, appears in no audit log format.
+func TestMaskedAuditLog(t *testing.T) {
+	const secret = "Bearer s3cr3t-t0k3n"
+
+	for _, format := range []string{auditFormatNative, auditFormatFlat, auditFormatOCSF} {
+		t.Run(format, func(t *testing.T) {
+			waf := newTestWAF(t, `SecRuleEngine On
+SecAuditEngine On
+SecAuditLogParts ABHKZ
+SecRule REQUEST_HEADERS:Authorization "@beginsWith Bearer" "id:1,phase:1,deny,status:403,log,msg:'token %{MATCHED_VAR}',logdata:'%{MATCHED_VAR}'"`)
+			if got := callInt(coraza_set_audit_log_format, waf, format); got != 0 {
+				t.Fatalf("coraza_set_audit_log_format = %d: %s", got, lastError)
+			}
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			if got := callInt(coraza_process_request_headers, tx, "GET", "/", "HTTP/1.1",
+				`[["Authorization","`+secret+`"]]`); got != 403 {
+				t.Fatalf("status = %d, want 403", got)
+			}
+
+			out, ok := callString(coraza_audit_log_json, tx)
+			if !ok {
+				t.Fatalf("coraza_audit_log_json: %s", lastError)
+			}
+			if strings.Contains(out, "s3cr3t") {
+				t.Errorf("secret leaked: %s", out)
+			}
+			if !strings.Contains(out, "token "+headerMask) {
+				t.Errorf("masked message missing: %s", out)
+			}
+		})
+	}
+}
+
+// TestMaskedGetters checks that a masked header value echoed in a rule's
+// message and logdata appears in none of the getters that report them.
+func TestMaskedGetters(t *testing.T) {
+	const secret = "Bearer s3cr3t-t0k3n"
+	waf := newTestWAF(t, `SecRuleEngine On
+SecRule REQUEST_HEADERS:Authorization "@beginsWith Bearer" "id:1,phase:1,deny,status:403,log,severity:2,msg:'token %{MATCHED_VAR}',logdata:'%{MATCHED_VAR}'"`)
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	if got := callInt(coraza_process_request_headers, tx, "GET", "/", "HTTP/1.1",
+		`[["Authorization","`+secret+`"]]`); got != 403 {
+		t.Fatalf("status = %d, want 403", got)
+	}
+
+	for name, fn := range map[string]any{
+		"coraza_get_decisive_rule_json":       coraza_get_decisive_rule_json,
+		"coraza_get_transaction_summary_json": coraza_get_transaction_summary_json,
+		"coraza_get_matched_rules_json":       coraza_get_matched_rules_json,
+		"coraza_get_matched_rules_cef":        coraza_get_matched_rules_cef,
+		"coraza_get_score_contributors_json":  coraza_get_score_contributors_json,
+		"coraza_get_matched_operators_json":   coraza_get_matched_operators_json,
+	} {
+		out, ok := callString(fn, tx)
+		if !ok {
+			t.Errorf("%s returned nil", name)
+			continue
+		}
+		if strings.Contains(out, "s3cr3t") {
+			t.Errorf("%s leaked the secret: %s", name, out)
+		}
+		if !strings.Contains(out, headerMask) {
+			t.Errorf("%s has no masked value: %s", name, out)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+typedef int (*coraza_match_cb)(uint64_t tx_id, int rule_id, const char *message);
+
+static inline int coraza_call_match_cb(coraza_match_cb cb, uint64_t tx_id, int rule_id, const char *message) {
+	return cb(tx_id, rule_id, message);
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// matchListeners maps the Coraza ID of each transaction with a match
+// callback to its state: map[string]*txState. Coraza reports matches
+// through a WAF-wide callback that only knows the transaction by that ID.
+var matchListeners sync.Map
+
+// abortedStatus is the status of an interruption requested by a match
+// callback.
+const abortedStatus = 403
+
+// coraza_set_match_callback registers cb to be called with the transaction
+// handle, rule ID and message each time a logging rule matches. The message
+// is masked (see coraza_set_masked_headers) and only valid during the call.
+// cb runs before the phase function returns, with no bridge lock held; it may
+// call the query functions for the handle but must not free it or run its
+// phase functions. A non-zero return interrupts the transaction with status
+// 403 when SecRuleEngine is On. Pass NULL to unregister. Returns 0 on success
+// or -1 for an unknown handle.
+//
+//export coraza_set_match_callback
+func coraza_set_match_callback(txID C.uint64_t, cb C.coraza_match_cb) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	st.matchCallback = (*byte)(unsafe.Pointer(cb))
+	if cb == nil {
+		matchListeners.Delete(st.tx.ID())
+	} else {
+		matchListeners.Store(st.tx.ID(), st)
+	}
+	return 0
+}
+
+// notifyMatch is the WAF error callback. It forwards the match to the
+// transaction's match callback, if any.
+func notifyMatch(mr types.MatchedRule) {
+	val, ok := matchListeners.Load(mr.TransactionID())
+	if !ok {
+		return
+	}
+	st := val.(*txState)
+	cb := st.matchCallback
+	if cb == nil {
+		return
+	}
+
+	msg := C.CString(st.maskMatched(mr, mr.Message()))
+	defer C.free(unsafe.Pointer(msg))
+	abort := C.coraza_call_match_cb(C.coraza_match_cb(unsafe.Pointer(cb)), C.uint64_t(st.txID), C.int(mr.Rule().ID()), msg)
+	if abort != 0 {
+		if ts, ok := st.tx.(interface{ Interrupt(*types.Interruption) }); ok {
+			ts.Interrupt(&types.Interruption{Status: abortedStatus, Action: "deny", RuleID: mr.Rule().ID()})
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_set_allowed_methods restricts this WAF's requests to the HTTP
+// methods in methodsJSON, a JSON array such as ["GET","HEAD","POST"].
+// Methods are case-sensitive. A request with any other method is interrupted
+// with status 405 (action "deny", rule 0) once its request headers have been
+// processed, with SecRuleEngine On. The rules still run first and their own
+// interruption takes precedence. Pass NULL or "null" to allow every method
+// again. Returns 0 on success or -1 for an unknown WAF or an invalid or empty
+// list (see coraza_last_error).
+//
+//export coraza_set_allowed_methods
+func coraza_set_allowed_methods(wafID C.uint64_t, methodsJSON *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	if methodsJSON == nil {
+		ws.allowedMethods.Store(nil)
+		return 0
+	}
+
+	var methods []string
+	if err := json.Unmarshal([]byte(C.GoString(methodsJSON)), &methods); err != nil {
+		setLastError(fmt.Errorf("invalid methods JSON: %w", err))
+		return -1
+	}
+	if methods == nil {
+		ws.allowedMethods.Store(nil)
+		return 0
+	}
+	if len(methods) == 0 {
+		setLastError(errors.New("no allowed methods given"))
+		return -1
+	}
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	ws.allowedMethods.Store(&allowed)
+	return 0
+}
+
+// checkMethod interrupts the transaction if the WAF has a method allowlist
+// that method is not on.
+func (st *txState) checkMethod(method string) {
+	allowed := st.waf.allowedMethods.Load()
+	if allowed == nil || (*allowed)[method] {
+		return
+	}
+	if ts, ok := st.tx.(plugintypes.TransactionState); ok {
+		ts.Interrupt(&types.Interruption{Status: http.StatusMethodNotAllowed, Action: "deny"})
+	}
+}
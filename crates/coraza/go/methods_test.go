@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestRequestMethods runs uncommon methods through a method restriction
+// modelled on CRS rule 911100.
+func TestRequestMethods(t *testing.T) {
+	tests := []struct {
+		method, uri string
+		path        string
+		blocked     bool
+	}{
+		{"GET", "/a?b=c", "/a", false},
+		{"OPTIONS", "*", "*", false},
+		{"CONNECT", "example.com:443", "example.com:443", true},
+		{"CONNECT", "192.0.2.1:8443", "192.0.2.1:8443", true},
+		{"PATCH", "/items/1", "/items/1", true},
+		{"FOO", "/", "/", true},
+	}
+
+	waf := newTestWAF(t, `SecRuleEngine On
+SecAction "id:900200,phase:1,pass,nolog,setvar:'tx.allowed_methods=GET HEAD POST OPTIONS'"
+SecRule REQUEST_METHOD "!@within %{tx.allowed_methods}" "id:911100,phase:1,deny,status:405,log,msg:'Method is not allowed by policy'"`)
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.uri, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			status := callInt(coraza_process_request_headers, tx, tt.method, tt.uri, "HTTP/1.1", "[]")
+			if blocked := status == 405; blocked != tt.blocked || (!blocked && status != 0) {
+				t.Errorf("status = %d, want blocked %v", status, tt.blocked)
+			}
+
+			for name, want := range map[string]string{
+				"REQUEST_METHOD":   tt.method,
+				"REQUEST_FILENAME": tt.path,
+				"URLENCODED_ERROR": "0",
+			} {
+				if got := collectionValue(t, tx, name, ""); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestMethodNotAllowedKeepsStatus checks the bridge's own 405 is reported as
+// is, whatever block status and action overrides the WAF has.
+func TestMethodNotAllowedKeepsStatus(t *testing.T) {
+	waf := newTestWAF(t, "SecRuleEngine On")
+	callInt(coraza_set_allowed_methods, waf, `["GET"]`)
+	callInt(coraza_set_block_status, waf, 418)
+	callInt(coraza_set_action_override, waf, "deny", "redirect:https://example.com/challenge")
+
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	if got := callInt(coraza_process_request_headers, tx, "PUT", "/", "HTTP/1.1", "[]"); got != 405 {
+		t.Errorf("status = %d, want 405", got)
+	}
+	if got, _ := callString(coraza_intervention_url, tx); got != "" {
+		t.Errorf("redirect URL = %q, want none", got)
+	}
+}
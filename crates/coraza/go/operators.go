@@ -0,0 +1,101 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"reflect"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// matchedOperator is one element of coraza_get_matched_operators_json.
+type matchedOperator struct {
+	RuleID     int      `json:"rule_id"`
+	ChainLevel int      `json:"chain_level"`
+	Operator   string   `json:"operator"`
+	Argument   string   `json:"argument"`
+	Values     []string `json:"values"`
+}
+
+// coraza_get_matched_operators_json returns, for every matched rule, the
+// operator that matched, as a JSON array of objects with the "rule_id", the
+// "chain_level" (0 for the rule itself, 1 and up for its chained rules), the
+// "operator" as written (e.g. "@rx", "!@pm", "@detectSQLi"), its "argument"
+// (the pattern or data file) and the variable "values" it matched. A chained
+// rule has one entry per link. Returns "[]" when nothing matched, or nil for
+// an unknown handle. The caller must free the returned string.
+//
+//export coraza_get_matched_operators_json
+func coraza_get_matched_operators_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	tx := st.tx
+
+	ops := []matchedOperator{}
+	for _, mr := range tx.MatchedRules() {
+		links := ruleOperators(tx, mr.Rule().ID())
+		for level, link := range links {
+			op := matchedOperator{
+				RuleID:     mr.Rule().ID(),
+				ChainLevel: level,
+				Operator:   link[0],
+				Argument:   link[1],
+				Values:     []string{},
+			}
+			for _, md := range mr.MatchedDatas() {
+				if md.ChainLevel() != level {
+					continue
+				}
+				if st.waf.masks(md.Variable(), md.Key()) {
+					op.Values = append(op.Values, headerMask)
+				} else {
+					op.Values = append(op.Values, md.Value())
+				}
+			}
+			ops = append(ops, op)
+		}
+	}
+	return jsonCString(ops)
+}
+
+// ruleOperators returns the operator function and argument of a rule and each
+// of its chained rules, in chain order. Coraza does not expose operators
+// through its public API, so they are read from the internal rule found in
+// the transaction's WAF. Rules without an operator (SecAction) yield nothing.
+func ruleOperators(tx types.Transaction, id int) [][2]string {
+	var links [][2]string
+	rule := findRule(tx, id)
+	for rule.Kind() == reflect.Pointer && !rule.IsNil() {
+		op := rule.Elem().FieldByName("operator")
+		if !op.IsValid() || op.IsNil() {
+			break
+		}
+		links = append(links, [2]string{
+			op.Elem().FieldByName("Function").String(),
+			op.Elem().FieldByName("Data").String(),
+		})
+		rule = rule.Elem().FieldByName("Chain")
+	}
+	return links
+}
+
+// findRule returns the *Rule with the given ID in the WAF the transaction
+// was created from, which is nil if there is none, or the zero Value if the
+// rules cannot be reached.
+func findRule(tx types.Transaction, id int) reflect.Value {
+	rules, ok := ruleGroup(tx)
+	if !ok {
+		return reflect.Value{}
+	}
+	find := rules.Addr().MethodByName("FindByID")
+	if !find.IsValid() {
+		return reflect.Value{}
+	}
+	return find.Call([]reflect.Value{reflect.ValueOf(id)})[0]
+}
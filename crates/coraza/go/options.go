@@ -0,0 +1,61 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+// wafOptions are the settings accepted by coraza_new_waf_with_options that
+// have no directive of their own or must be fixed before the first
+// transaction.
+type wafOptions struct {
+	// RequestBodyInMemoryLimit is how many request body bytes are buffered
+	// in memory before the rest spills to a temporary file. It defaults to
+	// the request body limit.
+	RequestBodyInMemoryLimit *int64 `json:"request_body_in_memory_limit"`
+}
+
+// coraza_new_waf_with_options is coraza_new_waf with additional settings
+// given as a JSON object:
+//
+//   - "request_body_in_memory_limit": bytes of each request body kept in
+//     memory before the rest spills to a temporary file; overrides
+//     SecRequestBodyInMemoryLimit. The default is the request body limit.
+//
+// Returns 0 and sets the last error for unknown or invalid options.
+//
+//export coraza_new_waf_with_options
+func coraza_new_waf_with_options(directives, optionsJSON *C.char) C.uint64_t {
+	var opts wafOptions
+	dec := json.NewDecoder(strings.NewReader(C.GoString(optionsJSON)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		setLastError(fmt.Errorf("invalid options JSON: %w", err))
+		return 0
+	}
+
+	directivesStr := C.GoString(directives)
+	cfg := coraza.NewWAFConfig().WithDirectives(directivesStr)
+	if l := opts.RequestBodyInMemoryLimit; l != nil {
+		if *l <= 0 {
+			setLastError(errors.New("request_body_in_memory_limit must be positive"))
+			return 0
+		}
+		cfg = cfg.WithRequestBodyInMemoryLimit(int(*l))
+	}
+	ws := newWAF(cfg, []string{directivesStr})
+	if ws == nil {
+		return 0
+	}
+	return C.uint64_t(registerWAF(ws))
+}
@@ -0,0 +1,58 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+// coraza_result is the layout written by coraza_result_packed.
+typedef struct {
+	int32_t status;
+	int32_t rule_id;
+	int32_t matched_rule_count;
+	int32_t max_severity;
+} coraza_result;
+*/
+import "C"
+
+import "unsafe"
+
+// coraza_result_packed writes the hot results of the transaction to out as
+// a coraza_result, for callers that cannot afford to parse JSON on every
+// request. The struct is 16 bytes: four int32_t fields in the host's byte
+// order, with no padding:
+//
+//	offset 0   status              interruption status, 0 if not interrupted
+//	offset 4   rule_id             ID of the interrupting rule, 0 if none
+//	offset 8   matched_rule_count  as coraza_matched_rule_count
+//	offset 12  max_severity        highest severity of the matched rules with
+//	                               a message and a severity (0 emergency to 7
+//	                               debug), -1 if none
+//
+// The status is the one coraza_intervention_status reports, so status and
+// rule_id are 0 on a dry-run WAF or with enforcement off. It returns the
+// size of the struct; if out is NULL or outLen is smaller, nothing is written.
+// Returns -1 for an unknown handle.
+//
+//export coraza_result_packed
+func coraza_result_packed(txID C.uint64_t, out unsafe.Pointer, outLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	size := C.int(unsafe.Sizeof(C.coraza_result{}))
+	if out == nil || outLen < size {
+		return size
+	}
+
+	tx := st.tx
+	res := C.coraza_result{
+		matched_rule_count: C.int32_t(len(tx.MatchedRules())),
+		max_severity:       C.int32_t(maxSeverity(tx)),
+	}
+	if it := st.enforcedInterruption(); it != nil {
+		res.status = C.int32_t(st.status(it))
+		res.rule_id = C.int32_t(it.RuleID)
+	}
+	*(*C.coraza_result)(out) = res
+	return size
+}
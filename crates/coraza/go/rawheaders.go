@@ -0,0 +1,81 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_process_request_headers_raw is coraza_process_request_headers for a
+// header block exactly as received: CRLF- or LF-terminated "Name: value"
+// lines, optionally ending with the blank line. Headers are added in wire
+// order, repeated names are kept as separate values, obsolete line folding is
+// joined with a single space and lines without a colon are ignored.
+//
+//export coraza_process_request_headers_raw
+func coraza_process_request_headers_raw(txID C.uint64_t, method, uri, protocol *C.char, rawHeaders unsafe.Pointer, rawLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	start := time.Now()
+
+	var raw []byte
+	if rawLen > 0 && rawHeaders != nil {
+		raw = C.GoBytes(rawHeaders, rawLen)
+	}
+	m, u, p := C.GoString(method), C.GoString(uri), C.GoString(protocol)
+	return st.guard(func() C.int {
+		defer st.complete(types.PhaseRequestHeaders, start)
+		return st.processRequestHeaders(m, u, p, parseRawHeaders(raw))
+	})
+}
+
+// parseRawHeaders splits a raw header block into name/value pairs, in order.
+func parseRawHeaders(raw []byte) [][2]string {
+	var headers [][2]string
+	for len(raw) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+			line, raw = raw[:i], raw[i+1:]
+		} else {
+			line, raw = raw, nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			break
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			// Obsolete line folding continues the previous value.
+			if n := len(headers); n > 0 {
+				cont := strings.TrimSpace(string(line))
+				if headers[n-1][1] == "" {
+					headers[n-1][1] = cont
+				} else if cont != "" {
+					headers[n-1][1] += " " + cont
+				}
+			}
+			continue
+		}
+
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		headers = append(headers, [2]string{
+			string(bytes.TrimSpace(name)),
+			string(bytes.Trim(value, " \t")),
+		})
+	}
+	return headers
+}
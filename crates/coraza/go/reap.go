@@ -0,0 +1,90 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+typedef void (*coraza_reap_cb)(uint64_t tx_id, int64_t age_ms);
+
+static inline void coraza_call_reap_cb(coraza_reap_cb cb, uint64_t tx_id, int64_t age_ms) {
+	cb(tx_id, age_ms);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// reapCallback is the C function notified of reaped transactions, or nil.
+var reapCallback atomic.Pointer[byte]
+
+// coraza_set_reap_callback registers cb to be called with the ID and age in
+// milliseconds of every transaction the library closes on the host's behalf
+// because it was never freed. It runs on the reaping thread, after the
+// handle has been invalidated. Pass NULL to unregister.
+//
+//export coraza_set_reap_callback
+func coraza_set_reap_callback(cb C.coraza_reap_cb) {
+	reapCallback.Store((*byte)(unsafe.Pointer(cb)))
+}
+
+// reap closes a leaked transaction and reports it to the reap and error
+// callbacks. It returns false if the handle was already freed.
+func reap(id uint64) bool {
+	val, ok := txInstances.LoadAndDelete(id)
+	if !ok {
+		return false
+	}
+	st := val.(*txState)
+	age := time.Since(st.createdAt)
+	st.close()
+
+	if cb := reapCallback.Load(); cb != nil {
+		C.coraza_call_reap_cb(C.coraza_reap_cb(unsafe.Pointer(cb)), C.uint64_t(id), C.int64_t(age.Milliseconds()))
+	}
+	reportError(fmt.Sprintf("reaped stale transaction %d after %d ms", id, age.Milliseconds()))
+	return true
+}
+
+// coraza_sweep_stale_transactions closes and frees every transaction created
+// more than maxAgeMs milliseconds ago, as a safety net against handles the
+// host leaks, and returns the number reaped or -1 if maxAgeMs is negative.
+// Each one is reported to the reap callback and, by ID, to the error
+// callback. The handles become invalid, so maxAgeMs must be well above the
+// longest legitimate request, including response streaming; a transaction
+// must not be swept while the host is still using it.
+//
+//export coraza_sweep_stale_transactions
+func coraza_sweep_stale_transactions(maxAgeMs C.int64_t) C.int {
+	if maxAgeMs < 0 {
+		return -1
+	}
+
+	// Ages past the range of time.Duration, some 292 years, are clamped
+	// rather than left to overflow into a cutoff in the future.
+	maxAge := time.Duration(math.MaxInt64)
+	if int64(maxAgeMs) < math.MaxInt64/int64(time.Millisecond) {
+		maxAge = time.Duration(maxAgeMs) * time.Millisecond
+	}
+	cutoff := time.Now().Add(-maxAge)
+	var stale []uint64
+	txInstances.Range(func(key, val any) bool {
+		if val.(*txState).createdAt.Before(cutoff) {
+			stale = append(stale, key.(uint64))
+		}
+		return true
+	})
+
+	n := 0
+	for _, id := range stale {
+		if reap(id) {
+			n++
+		}
+	}
+	return C.int(n)
+}
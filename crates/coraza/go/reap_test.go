@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweepStaleTransactionsBounds(t *testing.T) {
+	waf := newTestWAF(t, "SecRuleEngine On")
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+
+	for _, maxAgeMs := range []int64{-1, math.MinInt64} {
+		if got := callInt(coraza_sweep_stale_transactions, maxAgeMs); got != -1 {
+			t.Errorf("sweep(%d) = %d, want -1", maxAgeMs, got)
+		}
+	}
+	for _, maxAgeMs := range []int64{math.MaxInt64, math.MaxInt64 / 1000, 9_300_000_000_000} {
+		if got := callInt(coraza_sweep_stale_transactions, maxAgeMs); got != 0 {
+			t.Errorf("sweep(%d) = %d, want 0", maxAgeMs, got)
+		}
+	}
+	if got := callInt(coraza_transaction_phase, tx); got != 0 {
+		t.Fatalf("live transaction was reaped: phase = %d", got)
+	}
+}
+
+func TestSweepReportsReapedIDs(t *testing.T) {
+	var reported []string
+	defer func(prev func(string)) { reportError = prev }(reportError)
+	reportError = func(msg string) { reported = append(reported, msg) }
+
+	waf := newTestWAF(t, "SecRuleEngine On")
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	val, _ := txInstances.Load(tx)
+	val.(*txState).createdAt = time.Now().Add(-24 * time.Hour)
+
+	if got := callInt(coraza_sweep_stale_transactions, time.Hour.Milliseconds()); got != 1 {
+		t.Fatalf("sweep = %d, want 1", got)
+	}
+	want := fmt.Sprintf("reaped stale transaction %d after ", tx)
+	if len(reported) != 1 || !strings.HasPrefix(reported[0], want) {
+		t.Errorf("reported %q, want one message starting %q", reported, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_add_rule appends ruleDirective, e.g. a SecRule blocking an exploit
+// signature, to the live ruleset of a WAF. The WAF is rebuilt from the
+// directives it was created with (re-reading directive files) plus every rule
+// added so far, then swapped in atomically: new transactions use the new
+// ruleset while those in progress finish on the old one. Settings applied
+// through the bridge after creation, such as the tmp dir, GeoIP database and
+// block statuses, are kept. If the rule is invalid, e.g. a syntax error or a
+// duplicate ID, the WAF is left unchanged, *errOut receives the error text
+// (the caller must free it) and -1 is returned; errOut may be nil. Returns 0
+// on success or -1 for an unknown WAF.
+//
+//export coraza_add_rule
+func coraza_add_rule(wafID C.uint64_t, ruleDirective *C.char, errOut **C.char) C.int {
+	if errOut != nil {
+		*errOut = nil
+	}
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	rule := C.GoString(ruleDirective)
+	cfg := ws.cfg.WithDirectives(rule)
+	if err := ws.rebuild(cfg, ws.engineMode); err != nil {
+		setLastError(err)
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return -1
+	}
+	ws.cfg = cfg
+	ws.sources = append(ws.sources, rule)
+	return 0
+}
+
+// coraza_set_engine_mode overrides the WAF's SecRuleEngine for transactions
+// created from now on: "On", "Off" or "DetectionOnly" (case-insensitive), or
+// NULL to return to the configured mode. Like coraza_add_rule, it rebuilds
+// the WAF and swaps it in, so transactions in progress keep the mode they
+// started with, and the override is kept when rules are added later. Rules
+// using ctl:ruleEngine still change the mode of their own transaction.
+// Returns 0 on success or -1 for an unknown WAF, an invalid mode or a WAF from
+// coraza_new_waf_dryrun, which always evaluates with SecRuleEngine On (see
+// coraza_last_error).
+//
+//export coraza_set_engine_mode
+func coraza_set_engine_mode(wafID C.uint64_t, mode *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	if ws.dryRun {
+		setLastError(errors.New("the engine mode of a dry-run WAF cannot be changed"))
+		return -1
+	}
+	var engineMode string
+	if mode != nil {
+		status, err := types.ParseRuleEngineStatus(C.GoString(mode))
+		if err != nil {
+			setLastError(err)
+			return -1
+		}
+		engineMode = status.String()
+	}
+
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	if err := ws.rebuild(ws.cfg, engineMode); err != nil {
+		setLastError(err)
+		return -1
+	}
+	ws.engineMode = engineMode
+	return 0
+}
+
+// rebuild builds a WAF from cfg, overriding its SecRuleEngine with
+// engineMode if set, and makes it current along with its warnings, carrying
+// over the settings applied to the previous one. configMu must be held.
+func (ws *wafState) rebuild(cfg coraza.WAFConfig, engineMode string) error {
+	if engineMode != "" {
+		cfg = cfg.WithDirectives("SecRuleEngine " + engineMode)
+	}
+	waf, warnings, err := buildWAF(cfg)
+	if err != nil {
+		return err
+	}
+	if ws.tmpDir != "" {
+		setTmpDir(waf, ws.tmpDir)
+	}
+	if len(ws.responseMimeTypes) > 0 {
+		addResponseMimeTypes(waf, ws.responseMimeTypes)
+	}
+	ws.registerEngine(waf)
+	ws.waf.Store(waf)
+	ws.warnings = warnings
+	ws.reloadedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_set_force_request_body_inspection makes coraza_process_request_body
+// parse the body as URL-encoded form data (populating REQUEST_BODY and
+// ARGS_POST) when no body processor was selected for it, like
+// ctl:forceRequestBodyVariable=On. Without it, a body whose Content-Type is
+// missing or not one Coraza knows is never parsed, so rules on REQUEST_BODY
+// or ARGS do not see it. Content-Length plays no part either way: bodies
+// written with coraza_write_request_body, such as chunked ones, are
+// inspected whether or not it is present. It must be called before the
+// request body phase. Returns 0 on success or -1 for an unknown handle or
+// if the request body has already been processed.
+//
+//export coraza_set_force_request_body_inspection
+func coraza_set_force_request_body_inspection(txID C.uint64_t, on C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	if st.phase >= types.PhaseRequestBody {
+		setLastError(errors.New("request body already processed"))
+		return -1
+	}
+	v := reflect.ValueOf(st.tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return -1
+	}
+	field := v.Elem().FieldByName("ForceRequestBodyVariable")
+	if !field.CanSet() || field.Kind() != reflect.Bool {
+		return -1
+	}
+	field.SetBool(on != 0)
+	return 0
+}
+
+// coraza_should_read_request_body reports whether the request body is worth
+// reading and passing to the WAF after the request headers phase: 1 if the
+// transaction has not been interrupted and either the rule engine is not off
+// and SecRequestBodyAccess is on or the body is captured (see
+// coraza_set_capture_request_body); 0 otherwise, in which case the host may
+// stream the body upstream unbuffered (or, if interrupted, discard it).
+// DetectionOnly counts as on, since its rules still inspect the body. A
+// WebSocket handshake without Content-Length or Transfer-Encoding also
+// reports 0: what follows its headers are WebSocket frames, not a body.
+// Returns -1 for an unknown handle.
+//
+//export coraza_should_read_request_body
+func coraza_should_read_request_body(txID C.uint64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	tx := st.tx
+	if tx.IsInterrupted() || isBodilessUpgrade(tx) {
+		return 0
+	}
+	if st.captureRequestBody {
+		return 1
+	}
+	if tx.IsRuleEngineOff() || !tx.IsRequestBodyAccessible() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,195 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// Request line problems reported by coraza_get_uri_info_json.
+const (
+	requestLineInvalidMethod    = "invalid_method"
+	requestLineInvalidTarget    = "invalid_request_target"
+	requestLineInvalidEncoding  = "invalid_percent_encoding"
+	requestLineControlChars     = "control_characters"
+	requestLineFragment         = "fragment_in_target"
+	requestLineInvalidProtocol  = "invalid_protocol"
+	requestLineUnparseableQuery = "unparseable_query"
+)
+
+// httpToken matches a method (RFC 9110, section 5.6.2).
+var httpToken = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// httpVersions are the protocols hosts pass for the HTTP versions in use.
+var httpVersions = map[string]bool{
+	"HTTP/0.9": true, "HTTP/1.0": true, "HTTP/1.1": true,
+	"HTTP/2": true, "HTTP/2.0": true, "HTTP/3": true, "HTTP/3.0": true,
+}
+
+// uriInfo is the document returned by coraza_get_uri_info_json.
+type uriInfo struct {
+	Method     string   `json:"method"`
+	URI        string   `json:"uri"`
+	Protocol   string   `json:"protocol"`
+	Path       string   `json:"path"`
+	Query      string   `json:"query"`
+	Errors     []string `json:"errors"`
+	ParseError string   `json:"parse_error,omitempty"`
+}
+
+// coraza_get_uri_info_json returns the request line as the WAF processed it,
+// as a JSON object with the "method", "uri", "protocol", "path", "query",
+// "errors" and, when the parser reported one, "parse_error". "errors" lists
+// the problems found, in this order, and is "[]" for a well-formed line:
+//
+//	invalid_method            the method is not an HTTP token
+//	invalid_request_target    the target is in no valid request-target form
+//	control_characters        the target contains spaces or control bytes
+//	fragment_in_target        the target contains a "#" fragment
+//	invalid_percent_encoding  a "%" is not followed by two hex digits
+//	unparseable_query         the query arguments could not be extracted
+//	invalid_protocol          the protocol is not HTTP/0.9, 1.0, 1.1, 2 or 3
+//
+// Returns nil for an unknown handle or before the request headers have been
+// processed. The caller must free the returned string.
+//
+//export coraza_get_uri_info_json
+func coraza_get_uri_info_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok || st.requestLine == nil {
+		return nil
+	}
+	info := *st.requestLine
+	if vars, ok := txVariables(st.tx); ok {
+		info.Path = vars.RequestFilename().Get()
+		info.Query = vars.QueryString().Get()
+		// Coraza initializes URLENCODED_ERROR to "0".
+		if e := vars.UrlencodedError().Get(); e != "0" {
+			info.ParseError = e
+		}
+	}
+	if info.ParseError != "" && !isAuthorityForm(info.Method, info.URI) {
+		info.Errors = append(info.Errors, requestLineUnparseableQuery)
+	}
+	if !httpVersions[info.Protocol] {
+		info.Errors = append(info.Errors, requestLineInvalidProtocol)
+	}
+	return jsonCString(info)
+}
+
+// checkRequestLine records the request line and the problems found in its
+// method and target.
+func (st *txState) checkRequestLine(method, uri, protocol string) {
+	info := &uriInfo{Method: method, URI: uri, Protocol: protocol, Errors: []string{}}
+	if !httpToken.MatchString(method) {
+		info.Errors = append(info.Errors, requestLineInvalidMethod)
+	}
+	switch {
+	case strings.HasPrefix(uri, "/"), isAuthorityForm(method, uri):
+	case uri == "*" && method == "OPTIONS":
+	default:
+		if u, err := url.Parse(uri); err != nil || u.Scheme == "" || u.Host == "" {
+			info.Errors = append(info.Errors, requestLineInvalidTarget)
+		}
+	}
+	if strings.ContainsFunc(uri, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		info.Errors = append(info.Errors, requestLineControlChars)
+	}
+	if strings.Contains(uri, "#") {
+		info.Errors = append(info.Errors, requestLineFragment)
+	}
+	if !st.uriDecoded && !validPercentEncoding(uri) {
+		info.Errors = append(info.Errors, requestLineInvalidEncoding)
+	}
+	st.requestLine = info
+}
+
+// validPercentEncoding reports whether every "%" in s starts a valid escape.
+func validPercentEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return false
+		}
+		i += 2
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// coraza_set_uri_raw declares whether the URI later passed to
+// coraza_process_request_headers is raw as received on the wire (raw != 0,
+// the default) or has already had its path percent-decoded by the host
+// (raw == 0). For an already-decoded URI the path is not decoded a second
+// time, which affects these variables:
+//
+//   - REQUEST_FILENAME and REQUEST_BASENAME hold the path exactly as given.
+//   - REQUEST_URI holds the path re-encoded into a valid request-target.
+//   - REQUEST_URI_RAW and REQUEST_LINE hold the URI exactly as given.
+//
+// The query string (QUERY_STRING, ARGS_GET) is always treated as raw.
+// Returns 0 on success or -1 for an unknown handle.
+//
+//export coraza_set_uri_raw
+func coraza_set_uri_raw(txID C.uint64_t, raw C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	st.uriDecoded = raw == 0
+	return 0
+}
+
+// processDecodedURI runs ProcessURI for a URI whose path the host has already
+// percent-decoded. The path is re-escaped so Coraza's own decoding restores it
+// unchanged, and the raw variables are reset to the URI as given.
+func processDecodedURI(tx types.Transaction, uri, method, protocol string) {
+	path, query, hasQuery := strings.Cut(uri, "?")
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	if hasQuery {
+		escaped += "?" + query
+	}
+	tx.ProcessURI(escaped, method, protocol)
+
+	if vars, ok := txVariables(tx); ok {
+		setSingle(vars.RequestURIRaw(), uri)
+		setSingle(vars.RequestLine(), fmt.Sprintf("%s %s %s", method, uri, protocol))
+	}
+}
+
+// isAuthorityForm reports whether uri is the host:port request-target of a
+// CONNECT request (RFC 9110, section 9.3.6).
+func isAuthorityForm(method, uri string) bool {
+	return method == "CONNECT" && uri != "" && !strings.HasPrefix(uri, "/") && !strings.Contains(uri, "://")
+}
+
+// processAuthorityURI runs ProcessURI for a CONNECT target. Coraza parses
+// host:port as a URL, which leaves the path empty (or, for an IP address,
+// fails and sets URLENCODED_ERROR), so the path variables are set to the
+// authority itself, as ModSecurity does, and URLENCODED_ERROR is restored
+// to Coraza's "0".
+func processAuthorityURI(tx types.Transaction, uri, method, protocol string) {
+	tx.ProcessURI(uri, method, protocol)
+
+	if vars, ok := txVariables(tx); ok {
+		setSingle(vars.RequestURI(), uri)
+		setSingle(vars.RequestFilename(), uri)
+		setSingle(vars.RequestBasename(), uri)
+		setSingle(vars.QueryString(), "")
+		setSingle(vars.UrlencodedError(), "0")
+	}
+}
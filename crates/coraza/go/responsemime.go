@@ -0,0 +1,99 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+// coraza_add_response_mime_type adds a media type, such as
+// "application/x-ndjson", to the response Content-Types whose bodies the WAF
+// inspects (SecResponseBodyMimeType), without changing its directives. It
+// takes effect for transactions created afterwards and is kept when the WAF
+// is rebuilt by coraza_add_rule or coraza_set_engine_mode. Bodies are still
+// only inspected with SecResponseBodyAccess On. Returns 0 on success or -1
+// for an unknown WAF or an empty type.
+//
+//export coraza_add_response_mime_type
+func coraza_add_response_mime_type(wafID C.uint64_t, mime *C.char) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+	mimeType := strings.ToLower(strings.TrimSpace(C.GoString(mime)))
+	if mimeType == "" {
+		return -1
+	}
+
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	if !addResponseMimeTypes(ws.engine(), []string{mimeType}) {
+		return -1
+	}
+	ws.responseMimeTypes = append(ws.responseMimeTypes, mimeType)
+	return 0
+}
+
+// addResponseMimeTypes adds to the WAF's ResponseBodyMimeTypes the types it
+// does not have yet. Like TmpDir, the field is only reachable through a
+// transaction.
+func addResponseMimeTypes(waf coraza.WAF, mimeTypes []string) bool {
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	w, ok := internalWAF(tx)
+	if !ok {
+		return false
+	}
+	field := w.Elem().FieldByName("ResponseBodyMimeTypes")
+	current, ok := field.Interface().([]string)
+	if !ok || !field.CanSet() {
+		return false
+	}
+	// Copy rather than append in place, as transactions may be reading it.
+	updated := append([]string(nil), current...)
+	for _, m := range mimeTypes {
+		if !slices.Contains(updated, m) {
+			updated = append(updated, m)
+		}
+	}
+	field.Set(reflect.ValueOf(updated))
+	return true
+}
+
+// coraza_process_response_body_typed is coraza_process_response_body for a
+// body of the given Content-Type, which becomes RESPONSE_CONTENT_TYPE in
+// place of the one from the response headers. If the type is not one listed
+// in SecResponseBodyMimeType (and ctl:forceResponseBodyVariable is not set),
+// or response body access is off, the body is neither copied nor inspected
+// and phase 4 runs without it, as Coraza would do itself; otherwise it
+// behaves exactly like coraza_process_response_body, returning the
+// interruption status if a rule interrupts. A NULL or empty contentType
+// keeps the one from the response headers.
+//
+//export coraza_process_response_body_typed
+func coraza_process_response_body_typed(txID C.uint64_t, contentType *C.char, body unsafe.Pointer, bodyLen C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	tx := st.tx
+	if ct := C.GoString(contentType); ct != "" {
+		if vars, ok := txVariables(tx); ok {
+			mime, _, _ := strings.Cut(ct, ";")
+			setSingle(vars.ResponseContentType(), mime)
+		}
+	}
+	if !tx.IsResponseBodyAccessible() || !tx.IsResponseBodyProcessable() {
+		bodyLen = 0
+	}
+	return coraza_process_response_body(txID, body, bodyLen)
+}
@@ -0,0 +1,284 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_disable_rule_for_tx skips the given rule for this transaction only,
+// like ctl:ruleRemoveById. It must be called before the phase in which the
+// rule runs. Returns 0 on success or -1 for an unknown handle.
+//
+//export coraza_disable_rule_for_tx
+func coraza_disable_rule_for_tx(txID C.uint64_t, ruleID C.int) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+
+	remover, ok := tx.(interface{ RemoveRuleByID(id int) })
+	if !ok {
+		return -1
+	}
+	remover.RemoveRuleByID(int(ruleID))
+	return 0
+}
+
+// internalWAF returns the pointer to Coraza's internal WAF behind tx, which
+// carries settings the public API does not expose.
+func internalWAF(tx any) (reflect.Value, bool) {
+	v := reflect.ValueOf(tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	waf := v.Elem().FieldByName("WAF")
+	if !waf.IsValid() || waf.Kind() != reflect.Pointer || waf.IsNil() {
+		return reflect.Value{}, false
+	}
+	return waf, true
+}
+
+// ruleGroup returns the addressable internal rule group of tx's WAF.
+func ruleGroup(tx types.Transaction) (reflect.Value, bool) {
+	waf, ok := internalWAF(tx)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	rules := waf.Elem().FieldByName("Rules")
+	if !rules.IsValid() || !rules.CanAddr() {
+		return reflect.Value{}, false
+	}
+	return rules, true
+}
+
+// loadedRules returns the []Rule of the WAF the transaction was created
+// from, in evaluation order.
+func loadedRules(tx types.Transaction) (reflect.Value, bool) {
+	rules, ok := ruleGroup(tx)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	get := rules.Addr().MethodByName("GetRules")
+	if !get.IsValid() {
+		return reflect.Value{}, false
+	}
+	return get.Call(nil)[0], true
+}
+
+// coraza_paranoia_level returns the CRS paranoia level in effect for the WAF,
+// 0 if the ruleset does not set one, or -1 for an unknown WAF. CRS assigns the
+// level with setvar in phase 1, so it is read from a throwaway transaction.
+//
+//export coraza_paranoia_level
+func coraza_paranoia_level(wafID C.uint64_t) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	tx.ProcessURI("/", "GET", "HTTP/1.1")
+	tx.ProcessRequestHeaders()
+
+	if level, ok := txIntVar(tx, "paranoia_level", "blocking_paranoia_level"); ok {
+		return C.int(level)
+	}
+	return 0
+}
+
+// builtinDefaultAction is the default action list Coraza gives phase 2 rules
+// when no SecDefaultAction sets one.
+const builtinDefaultAction = "phase:2,log,auditlog,pass"
+
+// coraza_default_action returns the action lists set with SecDefaultAction,
+// e.g. "phase:2,log,auditlog,deny,status:403", one per line in the order they
+// were configured, in the directives or directive files the WAF was created
+// from and the rules added with coraza_add_rule; files pulled in with Include
+// are not read. Each applies to the rules of its phase defined after it,
+// whose disruptive action it supplies when they have none or use block.
+// Without any, Coraza's built-in "phase:2,log,auditlog,pass" is returned, so
+// rules log but do not block by default. Returns nil for an unknown WAF. The
+// caller must free the returned string.
+//
+//export coraza_default_action
+func coraza_default_action(wafID C.uint64_t) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+
+	ws.configMu.Lock()
+	actions := defaultActions(ws.sources)
+	ws.configMu.Unlock()
+	if len(actions) == 0 {
+		return C.CString(builtinDefaultAction)
+	}
+	return C.CString(strings.Join(actions, "\n"))
+}
+
+// defaultActions returns the actions of the SecDefaultAction directives in
+// sources, in order, splitting lines as Coraza's parser does. Coraza does
+// not keep them once the rules are built.
+func defaultActions(sources []string) []string {
+	var actions []string
+	for _, src := range sources {
+		var directive strings.Builder
+		for _, line := range strings.Split(src, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			if cont, ok := strings.CutSuffix(line, "\\"); ok {
+				directive.WriteString(cont)
+				continue
+			}
+			directive.WriteString(line)
+			name, opts, _ := strings.Cut(directive.String(), " ")
+			directive.Reset()
+			if !strings.EqualFold(name, "SecDefaultAction") {
+				continue
+			}
+			if len(opts) >= 3 && opts[0] == '"' && opts[len(opts)-1] == '"' {
+				opts = strings.Trim(opts, `"`)
+			}
+			actions = append(actions, opts)
+		}
+	}
+	return actions
+}
+
+// coraza_rule_phase returns the phase, 1 to 5, in which the rule with the
+// given ID is evaluated, or -1 for an unknown WAF or rule ID. Rules chained
+// to it run in the same phase.
+//
+//export coraza_rule_phase
+func coraza_rule_phase(wafID C.uint64_t, ruleID C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	rule := findRule(tx, int(ruleID))
+	if rule.Kind() != reflect.Pointer || rule.IsNil() {
+		return -1
+	}
+	md, ok := rule.Interface().(types.RuleMetadata)
+	if !ok {
+		return -1
+	}
+	return C.int(md.Phase())
+}
+
+// disruptiveActions are the names of SecLang's disruptive actions.
+var disruptiveActions = map[string]bool{
+	"allow": true, "block": true, "deny": true, "drop": true, "pass": true, "redirect": true,
+}
+
+// ruleMetadata is the document returned by coraza_get_rule_metadata_json.
+type ruleMetadata struct {
+	RuleID       int      `json:"rule_id"`
+	Phase        int      `json:"phase"`
+	Message      string   `json:"message"`
+	Tags         []string `json:"tags"`
+	Severity     *int     `json:"severity,omitempty"`
+	SeverityText string   `json:"severityText,omitempty"`
+	Action       string   `json:"action,omitempty"`
+	Status       int      `json:"status,omitempty"`
+}
+
+// coraza_get_rule_metadata_json returns the compiled metadata of the rule
+// with the given ID as a JSON object with its "rule_id", "phase", "message"
+// as written in the rule, before macro expansion, "tags", "severity" and
+// "severityText" as in coraza_get_matched_rules_json, its disruptive
+// "action" ("deny", "block", "pass", ...; omitted if it has none) and the
+// "status" set with the status action (omitted if none). It returns nil for
+// an unknown WAF or rule ID. The caller must free the returned string.
+//
+//export coraza_get_rule_metadata_json
+func coraza_get_rule_metadata_json(wafID C.uint64_t, ruleID C.int) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	rule := findRule(tx, int(ruleID))
+	if rule.Kind() != reflect.Pointer || rule.IsNil() {
+		return nil
+	}
+	md, ok := rule.Interface().(types.RuleMetadata)
+	if !ok {
+		return nil
+	}
+
+	meta := ruleMetadata{
+		RuleID: md.ID(),
+		Phase:  int(md.Phase()),
+		Tags:   md.Tags(),
+	}
+	meta.Severity, meta.SeverityText = severityFields(md)
+	if meta.Tags == nil {
+		meta.Tags = []string{}
+	}
+	r := rule.Elem()
+	if msg := r.FieldByName("Msg"); msg.IsValid() && !msg.IsNil() {
+		if m, ok := msg.Interface().(fmt.Stringer); ok {
+			meta.Message = m.String()
+		}
+	}
+	if status := r.FieldByName("DisruptiveStatus"); status.IsValid() && status.CanInt() {
+		meta.Status = int(status.Int())
+	}
+	if actions := r.FieldByName("actions"); actions.IsValid() && actions.Kind() == reflect.Slice {
+		for i := 0; i < actions.Len(); i++ {
+			if name := actions.Index(i).FieldByName("Name"); name.IsValid() && disruptiveActions[name.String()] {
+				meta.Action = name.String()
+			}
+		}
+	}
+	return jsonCString(meta)
+}
+
+// coraza_has_response_rules returns 1 if the WAF has any rule evaluated in
+// the response headers or response body phase (3 or 4), 0 if its policy only
+// inspects requests, in which case the host can skip buffering and passing
+// responses to it, or -1 for an unknown WAF. Logging phase rules do not
+// count; they run from coraza_finalize either way.
+//
+//export coraza_has_response_rules
+func coraza_has_response_rules(wafID C.uint64_t) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	list, ok := loadedRules(tx)
+	if !ok {
+		return 1
+	}
+	for i := 0; i < list.Len(); i++ {
+		md, ok := list.Index(i).Addr().Interface().(types.RuleMetadata)
+		if !ok {
+			return 1
+		}
+		if p := md.Phase(); p == types.PhaseResponseHeaders || p == types.PhaseResponseBody {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"reflect"
+)
+
+// coraza_get_ruleset_hash returns a hex SHA-256 digest identifying the
+// WAF's ruleset, for comparing deployments across a fleet. It covers the
+// directives the WAF was created from (the contents of directive files, not
+// their paths), the rules added with coraza_add_rule, and the text of every
+// loaded rule in evaluation order, so that rules pulled in by Include change
+// it too. Instances loaded from the same sources get the same hash. Settings
+// applied through other bridge functions are not part of it. Returns nil for
+// an unknown WAF. The caller must free the returned string.
+//
+//export coraza_get_ruleset_hash
+func coraza_get_ruleset_hash(wafID C.uint64_t) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+
+	h := sha256.New()
+	ws.configMu.Lock()
+	for _, src := range ws.sources {
+		writeHashString(h, src)
+	}
+	waf := ws.engine()
+	ws.configMu.Unlock()
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	if list, ok := loadedRules(tx); ok {
+		for i := 0; i < list.Len(); i++ {
+			for rule := list.Index(i).Addr(); rule.Kind() == reflect.Pointer && !rule.IsNil(); rule = rule.Elem().FieldByName("Chain") {
+				writeHashString(h, rule.Elem().FieldByName("Raw_").String())
+			}
+		}
+	}
+	return C.CString(hex.EncodeToString(h.Sum(nil)))
+}
+
+// writeHashString writes s to h prefixed with its length, so that
+// consecutive strings cannot run into each other.
+func writeHashString(h hash.Hash, s string) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(s)))
+	h.Write(n[:])
+	h.Write([]byte(s))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// ruleSeverity returns the severity of a rule, reporting false if it has no
+// severity action. Coraza reports such rules as 0 (emergency), so the action
+// is looked for in the rule's own directive; SecDefaultAction cannot set a
+// severity, and neither can chained rules.
+func ruleSeverity(rule types.RuleMetadata) (types.RuleSeverity, bool) {
+	// The directives of chained rules follow the rule's own.
+	raw, _, _ := strings.Cut(rule.Raw(), " \n")
+	for _, action := range splitActions(lastDirectiveArg(raw)) {
+		name, _, _ := strings.Cut(action, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "severity") {
+			return rule.Severity(), true
+		}
+	}
+	return 0, false
+}
+
+// severityFields returns the numeric and text severity of a rule for the
+// JSON getters, both unset if the rule has no severity action.
+func severityFields(rule types.RuleMetadata) (*int, string) {
+	s, ok := ruleSeverity(rule)
+	if !ok {
+		return nil, ""
+	}
+	n := s.Int()
+	return &n, s.String()
+}
+
+// lastDirectiveArg returns the last argument of a directive, the actions of
+// SecRule and SecAction, without its double quotes.
+func lastDirectiveArg(directive string) string {
+	var last string
+	for i := 0; i < len(directive); {
+		if unicode.IsSpace(rune(directive[i])) {
+			i++
+			continue
+		}
+		start := i
+		if directive[i] == '"' {
+			start++
+			for i++; i < len(directive) && directive[i] != '"'; i++ {
+				if directive[i] == '\\' {
+					i++
+				}
+			}
+			last = directive[start:min(i, len(directive))]
+			i++
+			continue
+		}
+		for i < len(directive) && !unicode.IsSpace(rune(directive[i])) {
+			i++
+		}
+		last = directive[start:i]
+	}
+	return last
+}
+
+// splitActions splits an action list at the commas outside single-quoted
+// values.
+func splitActions(actions string) []string {
+	var out []string
+	quoted, start := false, 0
+	for i := 0; i < len(actions); i++ {
+		switch actions[i] {
+		case '\\':
+			i++
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				out = append(out, actions[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, actions[start:])
+}
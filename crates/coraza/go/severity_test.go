@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRuleSeverity(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{"none", `SecRule ARGS "@rx a" "id:1,phase:1,pass,log,msg:'a'"`, ""},
+		{"set", `SecRule ARGS "@rx a" "id:1,phase:1,pass,log,severity:WARNING"`, "warning"},
+		{"numeric emergency", `SecRule ARGS "@rx a" "id:1,phase:1,pass,log,severity:0"`, "emergency"},
+		{"spaced", `SecRule ARGS "@rx a" "id:1, phase:1, pass, log, severity:'CRITICAL'"`, "critical"},
+		{"in message", `SecRule ARGS "@rx a" "id:1,phase:1,pass,log,msg:'x,severity:2'"`, ""},
+		{"in operator", `SecRule ARGS "!@rx severity:2" "id:1,phase:1,pass,log"`, ""},
+		{"escaped quote", `SecRule ARGS "!@rx \"" "id:1,phase:1,pass,log,severity:NOTICE"`, "notice"},
+		{"continued lines", "SecRule ARGS \"@rx a\" \\\n    \"id:1,phase:1,pass,log,\\\n    severity:ERROR\"", "error"},
+		{"action", `SecAction "id:1,phase:1,pass,log,severity:ALERT"`, "alert"},
+		{
+			"chained",
+			"SecRule ARGS \"@rx a\" \"id:1,phase:1,pass,log,chain,severity:INFO\"\nSecRule ARGS \"@rx a\" \"t:none\"",
+			"info",
+		},
+		{
+			"chained without",
+			"SecRule ARGS \"@rx a\" \"id:1,phase:1,pass,log,chain\"\nSecRule ARGS \"@rx a\" \"t:none\"",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := newTestWAF(t, "SecRuleEngine On\n"+tt.rule)
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			callInt(coraza_process_request_headers, tx, "GET", "/?a=a", "HTTP/1.1", "[]")
+
+			val, _ := txInstances.Load(tx)
+			matched := val.(*txState).tx.MatchedRules()
+			if len(matched) != 1 {
+				t.Fatalf("%d rules matched, want 1", len(matched))
+			}
+			got := ""
+			if s, ok := ruleSeverity(matched[0].Rule()); ok {
+				got = s.String()
+			}
+			if got != tt.want {
+				t.Errorf("severity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSeverityGetters checks how each getter reports a rule with a severity
+// and one without.
+func TestSeverityGetters(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecAuditEngine On
+SecAuditLogParts ABHKZ
+SecRule ARGS:a "@rx ." "id:1,phase:1,pass,log,msg:'with',severity:WARNING"
+SecRule ARGS:a "@rx ." "id:2,phase:1,pass,log,msg:'without'"`)
+	tx := uint64(callInt(coraza_new_transaction, waf))
+	defer call(coraza_free_transaction, tx)
+	callInt(coraza_process_request_headers, tx, "GET", "/?a=1", "HTTP/1.1", "[]")
+
+	out, _ := callString(coraza_get_matched_rules_json, tx)
+	var rules []map[string]any
+	if err := json.Unmarshal([]byte(out), &rules); err != nil || len(rules) != 2 {
+		t.Fatalf("coraza_get_matched_rules_json = %s", out)
+	}
+	if rules[0]["severity"] != 4.0 || rules[0]["severityText"] != "warning" {
+		t.Errorf("rule with a severity: %v", rules[0])
+	}
+	if _, ok := rules[1]["severity"]; ok {
+		t.Errorf("rule without a severity reports one: %v", rules[1])
+	}
+	if _, ok := rules[1]["severityText"]; ok {
+		t.Errorf("rule without a severity reports one: %v", rules[1])
+	}
+
+	for id, want := range map[int]string{1: `"severityText":"warning"`, 2: `"message":"without","tags":[],`} {
+		if out, _ := callString(coraza_get_rule_metadata_json, waf, id); !strings.Contains(out, want) || (id == 2 && strings.Contains(out, "severity")) {
+			t.Errorf("coraza_get_rule_metadata_json(%d) = %s", id, out)
+		}
+	}
+
+	cef, _ := callString(coraza_get_matched_rules_cef, tx)
+	lines := strings.Split(strings.TrimSpace(cef), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "|1|with|6|") || !strings.Contains(lines[1], "|2|without|Unknown|") {
+		t.Errorf("coraza_get_matched_rules_cef = %s", cef)
+	}
+
+	if out, _ := callString(coraza_get_transaction_summary_json, tx); !strings.Contains(out, `"max_severity":"warning"`) {
+		t.Errorf("coraza_get_transaction_summary_json = %s", out)
+	}
+
+	callInt(coraza_set_audit_log_format, waf, auditFormatFlat)
+	if out, _ := callString(coraza_audit_log_json, tx); !strings.Contains(out, `"severity":"warning"`) {
+		t.Errorf("flat audit log = %s", out)
+	}
+	callInt(coraza_set_audit_log_format, waf, auditFormatOCSF)
+	out, _ = callString(coraza_audit_log_json, tx)
+	if !strings.Contains(out, `"severity_id":3`) || strings.Count(out, `"severity":`) != 1 {
+		t.Errorf("OCSF audit log = %s", out)
+	}
+}
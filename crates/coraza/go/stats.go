@@ -0,0 +1,267 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// Process-wide counters reported by coraza_snapshot_stats_json.
+var (
+	activeWAFs         atomic.Int64
+	activeTransactions atomic.Int64
+	transactionsTotal  atomic.Uint64
+	interruptionsTotal atomic.Uint64
+
+	// blocksTotal counts interruptions handed back to the host, which
+	// excludes those simulated by dry-run WAFs. blocksByAction breaks them
+	// down by disruptive action: map[string]*atomic.Uint64.
+	blocksTotal    atomic.Uint64
+	blocksByAction sync.Map
+)
+
+// countBlock records an interruption returned to the host.
+func countBlock(action string) {
+	blocksTotal.Add(1)
+	c, ok := blocksByAction.Load(action)
+	if !ok {
+		c, _ = blocksByAction.LoadOrStore(action, new(atomic.Uint64))
+	}
+	c.(*atomic.Uint64).Add(1)
+}
+
+type blockCounters struct {
+	Interruptions uint64            `json:"interruptions"`
+	Blocks        uint64            `json:"blocks"`
+	ByAction      map[string]uint64 `json:"by_action"`
+}
+
+func snapshotBlockCounters() blockCounters {
+	out := blockCounters{
+		Interruptions: interruptionsTotal.Load(),
+		Blocks:        blocksTotal.Load(),
+		ByAction:      map[string]uint64{},
+	}
+	blocksByAction.Range(func(k, v any) bool {
+		out.ByAction[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return out
+}
+
+// coraza_get_block_counters_json returns process-wide interruption counters
+// as JSON: "interruptions" raised by any WAF, "blocks" actually returned to
+// the host (dry-run WAFs only raise interruptions) and "by_action", the
+// blocks per disruptive action such as "deny" or "redirect". The counters
+// cover every transaction since the library was loaded. The caller must free
+// the returned string.
+//
+//export coraza_get_block_counters_json
+func coraza_get_block_counters_json() *C.char {
+	return jsonCString(snapshotBlockCounters())
+}
+
+type statsSnapshot struct {
+	ActiveWAFs         int64         `json:"active_wafs"`
+	ActiveTransactions int64         `json:"active_transactions"`
+	TransactionsTotal  uint64        `json:"transactions_total"`
+	InterruptionsTotal uint64        `json:"interruptions_total"`
+	Memory             memoryStats   `json:"memory"`
+	BlockCounters      blockCounters `json:"block_counters"`
+	WAFs               []wafStats    `json:"wafs"`
+}
+
+// wafStats is the per-WAF breakdown of the counters.
+type wafStats struct {
+	ID                 uint64 `json:"id"`
+	Label              string `json:"label,omitempty"`
+	ActiveTransactions int64  `json:"active_transactions"`
+	TransactionsTotal  uint64 `json:"transactions_total"`
+	InterruptionsTotal uint64 `json:"interruptions_total"`
+}
+
+// memoryStats is the subset of the Go runtime's memory statistics useful for
+// sizing the library's footprint.
+type memoryStats struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	Goroutines     int    `json:"goroutines"`
+}
+
+// coraza_snapshot_stats_json returns all library metrics in one JSON object:
+// live WAF and transaction counts, the number of transactions created and
+// interruptions raised since the library was loaded, Go runtime memory
+// statistics, the block counters of coraza_get_block_counters_json, and the
+// same counters for each live WAF with its label. The caller must free the
+// returned string.
+//
+//export coraza_snapshot_stats_json
+func coraza_snapshot_stats_json() *C.char {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	return jsonCString(statsSnapshot{
+		ActiveWAFs:         activeWAFs.Load(),
+		ActiveTransactions: activeTransactions.Load(),
+		TransactionsTotal:  transactionsTotal.Load(),
+		InterruptionsTotal: interruptionsTotal.Load(),
+		Memory: memoryStats{
+			HeapAllocBytes: ms.HeapAlloc,
+			HeapInuseBytes: ms.HeapInuse,
+			SysBytes:       ms.Sys,
+			NumGC:          ms.NumGC,
+			Goroutines:     runtime.NumGoroutine(),
+		},
+		BlockCounters: snapshotBlockCounters(),
+		WAFs:          liveWAFStats(),
+	})
+}
+
+// wafListEntry is one element of coraza_list_wafs_json.
+type wafListEntry struct {
+	ID    uint64 `json:"id"`
+	Label string `json:"label,omitempty"`
+}
+
+// coraza_list_wafs_json returns the live WAFs as a JSON array of objects with
+// their "id" and, if set, "label", in creation order. The caller must free the
+// returned string.
+//
+//export coraza_list_wafs_json
+func coraza_list_wafs_json() *C.char {
+	wafs := []wafListEntry{}
+	for _, ws := range liveWAFs() {
+		wafs = append(wafs, wafListEntry{ID: ws.id, Label: ws.label})
+	}
+	return jsonCString(wafs)
+}
+
+// wafInfo is the result of coraza_get_waf_info_json.
+type wafInfo struct {
+	ID           uint64 `json:"id"`
+	Label        string `json:"label,omitempty"`
+	CreatedAtMs  int64  `json:"created_at_ms"`
+	ReloadedAtMs int64  `json:"last_reload_at_ms,omitempty"`
+	UptimeMs     int64  `json:"uptime_ms"`
+	RuleCount    int    `json:"rule_count"`
+	EngineMode   string `json:"engine_mode,omitempty"`
+}
+
+// coraza_get_waf_info_json describes a WAF instance as a JSON object: its
+// "id" and "label", when it was created ("created_at_ms") and last rebuilt by
+// coraza_add_rule or coraza_set_engine_mode ("last_reload_at_ms", omitted if
+// never), both in milliseconds since the Unix epoch, how long ago it was
+// created ("uptime_ms"), and the number of rules ("rule_count", not counting
+// chained rules) and SecRuleEngine mode ("engine_mode") new transactions get.
+// Returns nil for an unknown WAF. The caller must free the returned string.
+//
+//export coraza_get_waf_info_json
+func coraza_get_waf_info_json(wafID C.uint64_t) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+
+	ws.configMu.Lock()
+	reloadedAt := ws.reloadedAt
+	ws.configMu.Unlock()
+	info := wafInfo{
+		ID:          ws.id,
+		Label:       ws.label,
+		CreatedAtMs: ws.createdAt.UnixMilli(),
+		UptimeMs:    time.Since(ws.createdAt).Milliseconds(),
+	}
+	if !reloadedAt.IsZero() {
+		info.ReloadedAtMs = reloadedAt.UnixMilli()
+	}
+
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	if rules, ok := loadedRules(tx); ok {
+		info.RuleCount = rules.Len()
+	}
+	if waf, ok := internalWAF(tx); ok {
+		if mode := waf.Elem().FieldByName("RuleEngine"); mode.IsValid() && mode.CanInt() {
+			info.EngineMode = types.RuleEngineStatus(mode.Int()).String()
+		}
+	}
+	return jsonCString(info)
+}
+
+func liveWAFStats() []wafStats {
+	out := []wafStats{}
+	for _, ws := range liveWAFs() {
+		out = append(out, wafStats{
+			ID:                 ws.id,
+			Label:              ws.label,
+			ActiveTransactions: ws.activeTransactions.Load(),
+			TransactionsTotal:  ws.transactionsTotal.Load(),
+			InterruptionsTotal: ws.interruptionsTotal.Load(),
+		})
+	}
+	return out
+}
+
+// liveWAFs returns the registered WAFs ordered by handle.
+func liveWAFs() []*wafState {
+	var wafs []*wafState
+	wafInstances.Range(func(_, v any) bool {
+		wafs = append(wafs, v.(*wafState))
+		return true
+	})
+	sort.Slice(wafs, func(i, j int) bool { return wafs[i].id < wafs[j].id })
+	return wafs
+}
+
+// coraza_flush_pools asks the Go runtime to release idle memory, such as the
+// transactions Coraza keeps pooled for reuse after a traffic spike, and
+// returns the number of heap objects freed. It is advisory: pools are owned
+// by the garbage collector, which empties them on its own within two cycles,
+// so this only brings that forward, and the count includes any other garbage
+// collected at the same time. It stops the world for a full collection, so
+// call it rarely, e.g. when the host detects memory pressure.
+//
+//export coraza_flush_pools
+func coraza_flush_pools() C.int {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	// Pooled objects survive one collection in the pool's victim cache.
+	runtime.GC()
+	debug.FreeOSMemory()
+	runtime.ReadMemStats(&after)
+	if after.HeapObjects >= before.HeapObjects {
+		return 0
+	}
+	return C.int(before.HeapObjects - after.HeapObjects)
+}
+
+// coraza_new_waf_labeled is coraza_new_waf for a WAF serving one tenant of a
+// multi-tenant gateway. The label is reported with the WAF's metrics in
+// coraza_snapshot_stats_json and coraza_list_wafs_json and with its audit
+// records in coraza_audit_log_json.
+//
+//export coraza_new_waf_labeled
+func coraza_new_waf_labeled(directives, label *C.char) C.uint64_t {
+	directivesStr := C.GoString(directives)
+	cfg := coraza.NewWAFConfig().WithDirectives(directivesStr)
+	ws := newWAF(cfg, []string{directivesStr})
+	if ws == nil {
+		return 0
+	}
+	ws.label = C.GoString(label)
+	return C.uint64_t(registerWAF(ws))
+}
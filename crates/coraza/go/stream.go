@@ -0,0 +1,205 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// coraza_set_response_streaming makes coraza_write_response_body inspect
+// each complete event (terminated by a blank line) of a streamed response,
+// such as server-sent events, as it arrives rather than buffering the body.
+// Each event is placed in RESPONSE_BODY for the response body rules that
+// inspect it; the others run once, with RESPONSE_BODY empty, when
+// coraza_process_response_body closes the stream. Returns 0 on success or -1
+// for an unknown handle.
+//
+//export coraza_set_response_streaming
+func coraza_set_response_streaming(txID C.uint64_t, on C.int) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	st.responseStreaming = on != 0
+	return 0
+}
+
+// writeResponseStream appends buf to the pending stream data and runs the
+// response body rules on every complete event. When final is set the stream
+// has closed: any trailing partial event is inspected as well, then the
+// response body phase is completed.
+func (st *txState) writeResponseStream(buf []byte, final bool) C.int {
+	tx := st.tx
+	if it := tx.Interruption(); it != nil {
+		return st.interrupted(types.PhaseResponseBody, it)
+	}
+
+	// A single event is never buffered past the response body limit.
+	limit := responseBodyLimit(tx)
+	st.streamPending = append(st.streamPending, buf...)
+	for {
+		end, next := sseEventEnd(st.streamPending)
+		if end < 0 {
+			if int64(len(st.streamPending)) < limit && !final {
+				return 0
+			}
+			end, next = len(st.streamPending), len(st.streamPending)
+		}
+		if end > 0 {
+			event := st.streamPending[:min(int64(end), limit)]
+			if !st.inspectResponseEvent(event) {
+				return inspectionError()
+			}
+		}
+		st.streamPending = st.streamPending[next:]
+
+		if it := tx.Interruption(); it != nil {
+			return st.interrupted(types.PhaseResponseBody, it)
+		}
+		if len(st.streamPending) == 0 {
+			st.streamPending = nil
+			break
+		}
+	}
+	if !final {
+		return 0
+	}
+
+	if it, err := tx.ProcessResponseBody(); it != nil {
+		return st.interrupted(types.PhaseResponseBody, it)
+	} else if err != nil {
+		return inspectionError()
+	}
+	return 0
+}
+
+// sseEventEnd returns the end of the first event in buf and the offset just
+// past its blank-line terminator, or -1 if buf holds no complete event.
+func sseEventEnd(buf []byte) (end, next int) {
+	end, next = -1, -1
+	for _, sep := range [][]byte{[]byte("\r\n\r\n"), []byte("\n\n"), []byte("\r\r")} {
+		if i := bytes.Index(buf, sep); i >= 0 && (end < 0 || i < end) {
+			end, next = i, i+len(sep)
+		}
+	}
+	return end, next
+}
+
+// inspectResponseEvent evaluates the response body rules that inspect
+// RESPONSE_BODY against a single event of a streamed response. Coraza
+// evaluates each phase only once per transaction, so the event is placed in
+// RESPONSE_BODY and the rules are run directly, after which the transaction
+// is rewound to the response headers phase for ProcessResponseBody.
+func (st *txState) inspectResponseEvent(event []byte) bool {
+	tx := st.tx
+	if tx.IsRuleEngineOff() || !tx.IsResponseBodyAccessible() {
+		return true
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return false
+	}
+	if !st.streamRules.IsValid() {
+		if st.streamRules, ok = responseBodyRules(tx); !ok {
+			return false
+		}
+	}
+	if !setSingle(vars.ResponseBody(), string(event)) {
+		return false
+	}
+	setSingle(vars.ResponseContentLength(), strconv.Itoa(len(event)))
+	return evalRules(st.streamRules, tx, types.PhaseResponseBody) && setLastPhase(tx, types.PhaseResponseHeaders)
+}
+
+// responseBodyRules returns a rule group with the response body phase rules
+// of tx's WAF that inspect RESPONSE_BODY in any rule of their chain, the
+// rules that skip and the SecMarkers, in their original order.
+func responseBodyRules(tx types.Transaction) (reflect.Value, bool) {
+	rules, ok := ruleGroup(tx)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	all, ok := unexportedField(rules, "rules")
+	if !ok || all.Kind() != reflect.Slice {
+		return reflect.Value{}, false
+	}
+
+	group := reflect.New(rules.Type())
+	subset, _ := unexportedField(group.Elem(), "rules")
+	for i := 0; i < all.Len(); i++ {
+		r := all.Index(i)
+		if r.FieldByName("SecMark_").String() != "" ||
+			(types.RulePhase(r.FieldByName("Phase_").Int()) == types.PhaseResponseBody &&
+				(ruleSkips(r) || chainInspects(r, variables.ResponseBody))) {
+			subset.Set(reflect.Append(subset, r))
+		}
+	}
+	return group, true
+}
+
+// ruleSkips reports whether the internal rule r has a skip or skipAfter
+// action.
+func ruleSkips(r reflect.Value) bool {
+	actions := r.FieldByName("actions")
+	for i := 0; actions.IsValid() && i < actions.Len(); i++ {
+		name := actions.Index(i).FieldByName("Name").String()
+		if strings.EqualFold(name, "skip") || strings.EqualFold(name, "skipAfter") {
+			return true
+		}
+	}
+	return false
+}
+
+// chainInspects reports whether the internal rule r, or a rule chained to
+// it, has v among its variables.
+func chainInspects(r reflect.Value, v variables.RuleVariable) bool {
+	for {
+		vars := r.FieldByName("variables")
+		for i := 0; vars.IsValid() && i < vars.Len(); i++ {
+			if f := vars.Index(i).FieldByName("Variable"); f.CanUint() && f.Uint() == uint64(v) {
+				return true
+			}
+		}
+		chain := r.FieldByName("Chain")
+		if !chain.IsValid() || chain.Kind() != reflect.Pointer || chain.IsNil() {
+			return false
+		}
+		r = chain.Elem()
+	}
+}
+
+// setLastPhase sets the phase Coraza records as the last one evaluated.
+func setLastPhase(tx types.Transaction, phase types.RulePhase) bool {
+	v := reflect.ValueOf(tx)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := unexportedField(v.Elem(), "lastPhase")
+	if !ok || f.Kind() != reflect.Int {
+		return false
+	}
+	f.SetInt(int64(phase))
+	return true
+}
+
+// evalRules runs the rules of a single phase from group, a pointer to one of
+// Coraza's internal rule groups, against tx. Coraza only exposes this on the
+// rule group itself.
+func evalRules(group reflect.Value, tx types.Transaction, phase types.RulePhase) bool {
+	eval := group.MethodByName("Eval")
+	if !eval.IsValid() {
+		return false
+	}
+	eval.Call([]reflect.Value{reflect.ValueOf(phase), reflect.ValueOf(tx)})
+	return true
+}
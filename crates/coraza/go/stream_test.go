@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+const sseTestRules = `SecRuleEngine On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/event-stream
+SecRule ARGS:skip "@eq 1" "id:1,phase:1,pass,nolog,setvar:tx.skip_body=1"
+SecRule TX:skip_body "@eq 1" "id:10,phase:4,pass,nolog,skipAfter:END-BODY"
+SecRule RESPONSE_BODY "@contains secret" "id:11,phase:4,pass,log,setvar:tx.leaks=+1"
+SecRule RESPONSE_HEADERS:Content-Type "@contains event-stream" "id:12,phase:4,pass,nolog,chain"
+	SecRule RESPONSE_BODY "@contains token" "setvar:tx.tokens=+1"
+SecRule RESPONSE_BODY "@contains forbidden" "id:13,phase:4,deny,status:403"
+SecMarker END-BODY
+SecRule RESPONSE_HEADERS:Content-Type "@contains event-stream" "id:14,phase:4,pass,nolog,setvar:tx.header_hits=+1"
+SecAction "id:15,phase:4,pass,nolog,setvar:tx.phase_runs=+1"`
+
+// TestResponseStreaming streams several server-sent events and checks that
+// each is inspected by the rules on RESPONSE_BODY alone, and that the other
+// response body rules run once, when the stream closes.
+func TestResponseStreaming(t *testing.T) {
+	tests := []struct {
+		name   string
+		uri    string
+		chunks []string
+		// wantStatus is the status of each write, then of closing the
+		// stream.
+		wantStatus []int64
+		wantTX     map[string]string
+	}{
+		{
+			name: "events",
+			uri:  "/",
+			chunks: []string{
+				"data: secret 1\n\n",
+				"data: secret",
+				" 2 token\n\ndata: plain\n\ndata: secret 3\n\n",
+				"data: trailing token",
+			},
+			wantStatus: []int64{0, 0, 0, 0, 0},
+			wantTX:     map[string]string{"leaks": "3", "tokens": "2", "header_hits": "1", "phase_runs": "1"},
+		},
+		{
+			name:       "skipped",
+			uri:        "/?skip=1",
+			chunks:     []string{"data: secret forbidden\n\n", "data: secret\n\n"},
+			wantStatus: []int64{0, 0, 0},
+			wantTX:     map[string]string{"leaks": "", "header_hits": "1", "phase_runs": "1"},
+		},
+		{
+			name:       "blocked mid-stream",
+			uri:        "/",
+			chunks:     []string{"data: secret\n\n", "data: forbidden\n\n", "data: secret\n\n"},
+			wantStatus: []int64{0, 403, 403, 403},
+			wantTX:     map[string]string{"leaks": "1", "header_hits": "", "phase_runs": ""},
+		},
+	}
+
+	waf := newTestWAF(t, sseTestRules)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			callInt(coraza_process_request_headers, tx, "GET", tt.uri, "HTTP/1.1", "[]")
+			callInt(coraza_process_request_body, tx, nil, 0)
+			callInt(coraza_process_response_headers, tx, 200, `[["Content-Type","text/event-stream"]]`)
+			callInt(coraza_set_response_streaming, tx, 1)
+
+			for i, chunk := range tt.chunks {
+				b := []byte(chunk)
+				if got := callInt(coraza_write_response_body, tx, unsafe.Pointer(&b[0]), len(b)); got != tt.wantStatus[i] {
+					t.Errorf("write %d: status = %d, want %d", i, got, tt.wantStatus[i])
+				}
+			}
+			if got, want := callInt(coraza_process_response_body, tx, nil, 0), tt.wantStatus[len(tt.chunks)]; got != want {
+				t.Errorf("close: status = %d, want %d", got, want)
+			}
+			for key, want := range tt.wantTX {
+				if got := collectionValue(t, tx, "TX", key); got != want {
+					t.Errorf("TX:%s = %q, want %q", key, got, want)
+				}
+			}
+			if got := callInt(coraza_transaction_phase, tx); got != 4 {
+				t.Errorf("phase = %d, want 4", got)
+			}
+		})
+	}
+}
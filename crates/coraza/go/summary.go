@@ -0,0 +1,83 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"sort"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// txSummary is the document returned by coraza_get_transaction_summary_json.
+type txSummary struct {
+	ID               string        `json:"id"`
+	Interruption     *decisiveRule `json:"interruption"`
+	MaxSeverity      string        `json:"max_severity,omitempty"`
+	AnomalyScores    anomalyScores `json:"anomaly_scores"`
+	MatchedRuleCount int           `json:"matched_rule_count"`
+	Tags             []string      `json:"tags"`
+	Phase            int           `json:"phase"`
+	ElapsedUs        int64         `json:"elapsed_us"`
+}
+
+// coraza_get_transaction_summary_json returns, in one JSON object, what a
+// host typically logs once a transaction is done: its Coraza "id", the
+// "interruption" as coraza_get_decisive_rule_json reports it (null if none),
+// the "max_severity" of the matched rules that carry a message and a
+// severity (omitted if none), the "anomaly_scores" as
+// coraza_get_anomaly_scores_json reports them, the "matched_rule_count", the
+// sorted, distinct "tags" of the matched rules, the "phase" reached as
+// coraza_transaction_phase reports it and "elapsed_us", the time spent in the
+// WAF. Returns nil for an unknown handle. The caller must free the returned
+// string.
+//
+//export coraza_get_transaction_summary_json
+func coraza_get_transaction_summary_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	tx := st.tx
+
+	sum := txSummary{
+		ID:            tx.ID(),
+		Interruption:  st.decisiveRule(),
+		AnomalyScores: txAnomalyScores(tx),
+		Tags:          []string{},
+		Phase:         int(st.phase),
+		ElapsedUs:     st.elapsed().Microseconds(),
+	}
+	seen := map[string]bool{}
+	for _, mr := range tx.MatchedRules() {
+		sum.MatchedRuleCount++
+		for _, tag := range mr.Rule().Tags() {
+			if !seen[tag] {
+				seen[tag] = true
+				sum.Tags = append(sum.Tags, tag)
+			}
+		}
+	}
+	if severity := maxSeverity(tx); severity >= 0 {
+		sum.MaxSeverity = severity.String()
+	}
+	sort.Strings(sum.Tags)
+	return jsonCString(sum)
+}
+
+// maxSeverity returns the highest severity (the lowest number) among the
+// matched rules that carry a message and a severity, or -1 if there are
+// none.
+func maxSeverity(tx types.Transaction) types.RuleSeverity {
+	severity := types.RuleSeverity(-1)
+	for _, mr := range tx.MatchedRules() {
+		s, ok := ruleSeverity(mr.Rule())
+		if ok && mr.Message() != "" && (severity < 0 || s < severity) {
+			severity = s
+		}
+	}
+	return severity
+}
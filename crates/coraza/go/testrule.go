@@ -0,0 +1,122 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/corazawaf/coraza/v3"
+)
+
+// testRulePreamble configures the WAF coraza_test_rule builds around the
+// rules under test.
+const testRulePreamble = `SecRuleEngine On
+SecRequestBodyAccess On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain text/html application/json
+`
+
+// testRuleResult is the result document of coraza_test_rule.
+type testRuleResult struct {
+	Matched      bool               `json:"matched"`
+	Matches      []testRuleMatch    `json:"matches"`
+	Captures     map[string]string  `json:"captures"`
+	Interruption *phaseInterruption `json:"interruption,omitempty"`
+}
+
+type testRuleMatch struct {
+	RuleID    int                `json:"rule_id"`
+	Message   string             `json:"message"`
+	Data      string             `json:"data"`
+	Variables []testRuleMatchVar `json:"variables"`
+}
+
+type testRuleMatchVar struct {
+	Name  string `json:"name"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value"`
+}
+
+// coraza_test_rule evaluates one or more SecRule directives against a sample
+// request, for rule development. The rules run alone, with the engine on and
+// body access enabled (response bodies for text, HTML and JSON), against
+// inputJSON in the format of coraza_warmup's samples plus the optional
+// "response_status", "response_headers" and "response_body". If resultOut is
+// not nil it receives a JSON document with "matched", the "matches" (rule,
+// message, logdata and matched variables), the "captures" (TX.0-TX.9 set by
+// the capture action) and the "interruption", if any; the caller must free
+// it. Returns 1 if any rule matched, 0 if none did, or -1 if the rules or
+// input are invalid (see coraza_last_error).
+//
+//export coraza_test_rule
+func coraza_test_rule(rule, inputJSON *C.char, resultOut **C.char) C.int {
+	if resultOut != nil {
+		*resultOut = nil
+	}
+
+	var input sampleRequest
+	if err := json.Unmarshal([]byte(C.GoString(inputJSON)), &input); err != nil {
+		setLastError(fmt.Errorf("invalid input JSON: %w", err))
+		return -1
+	}
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(
+		testRulePreamble + C.GoString(rule)))
+	if err != nil {
+		setLastError(err)
+		return -1
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	input.run(tx)
+
+	result := testRuleResult{Matches: []testRuleMatch{}, Captures: map[string]string{}}
+	for _, mr := range tx.MatchedRules() {
+		m := testRuleMatch{
+			RuleID:    mr.Rule().ID(),
+			Message:   mr.Message(),
+			Data:      mr.Data(),
+			Variables: []testRuleMatchVar{},
+		}
+		for _, md := range mr.MatchedDatas() {
+			m.Variables = append(m.Variables, testRuleMatchVar{
+				Name:  md.Variable().Name(),
+				Key:   md.Key(),
+				Value: md.Value(),
+			})
+		}
+		result.Matches = append(result.Matches, m)
+	}
+	result.Matched = len(result.Matches) > 0
+	if vars, ok := txVariables(tx); ok {
+		for i := 0; i < 10; i++ {
+			k := strconv.Itoa(i)
+			if v := vars.TX().Get(k); len(v) > 0 && v[0] != "" {
+				result.Captures[k] = v[0]
+			}
+		}
+	}
+	if it := tx.Interruption(); it != nil {
+		result.Interruption = &phaseInterruption{Status: it.Status, Action: it.Action, RuleID: it.RuleID}
+		for _, mr := range tx.MatchedRules() {
+			if mr.Rule().ID() == it.RuleID {
+				result.Interruption.Phase = int(mr.Rule().Phase())
+				break
+			}
+		}
+	}
+
+	if resultOut != nil {
+		*resultOut = jsonCString(result)
+	}
+	if result.Matched {
+		return 1
+	}
+	return 0
+}
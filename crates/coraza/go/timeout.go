@@ -0,0 +1,98 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// processingTimedOut is returned by a phase function whose rule evaluation
+// exceeded the processing timeout or the transaction's deadline.
+const processingTimedOut = -4
+
+// processingTimeout bounds the time a phase function waits for Coraza; 0
+// waits indefinitely.
+var processingTimeout atomic.Int64 // time.Duration
+
+// coraza_set_processing_timeout limits how long each phase function, from
+// coraza_process_request_headers to coraza_finalize, waits for rule
+// evaluation to finish: ms milliseconds, or 0 (the default) for no limit. A
+// phase that takes longer returns -4 and the transaction becomes unusable:
+// every other function treats it as an unknown handle, and
+// coraza_free_transaction only releases it once Coraza has returned, as the
+// evaluation itself cannot be stopped. The timeout applies to all WAFs.
+// Returns 0 on success or -1 for a negative timeout.
+//
+//export coraza_set_processing_timeout
+func coraza_set_processing_timeout(ms C.int) C.int {
+	if ms < 0 {
+		return -1
+	}
+	processingTimeout.Store(int64(time.Duration(ms) * time.Millisecond))
+	return 0
+}
+
+// coraza_set_transaction_deadline gives the transaction an absolute
+// deadline, in milliseconds since the Unix epoch, such as the host's overall
+// request deadline. Each later phase function returns -4 without evaluating
+// anything once it has passed, and one that is still evaluating when it
+// passes returns -4 as with coraza_set_processing_timeout, leaving the
+// transaction unusable. The processing timeout still applies to each phase
+// if it expires first. Pass 0 to remove the deadline. Returns 0 on success
+// or -1 for an unknown handle or a negative time.
+//
+//export coraza_set_transaction_deadline
+func coraza_set_transaction_deadline(txID C.uint64_t, unixMillis C.int64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok || unixMillis < 0 {
+		return -1
+	}
+	if unixMillis == 0 {
+		st.deadline = time.Time{}
+		return 0
+	}
+	st.deadline = time.UnixMilli(int64(unixMillis))
+	return 0
+}
+
+// guard runs fn, the rule evaluation of a phase function, within the
+// processing timeout and the transaction's deadline. If fn overruns, the
+// transaction is marked runaway and fn keeps running in the background, so fn
+// must do all of the call's bookkeeping on st itself: once guard has returned
+// processingTimedOut, the caller must not touch st again.
+func (st *txState) guard(fn func() C.int) C.int {
+	deadline := st.deadline
+	if timeout := time.Duration(processingTimeout.Load()); timeout > 0 {
+		if d := time.Now().Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return fn()
+	}
+	id := st.tx.ID()
+	if !st.deadline.IsZero() && !time.Now().Before(st.deadline) {
+		setLastError(fmt.Errorf("transaction %s: deadline exceeded", id))
+		return processingTimedOut
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	done := make(chan C.int, 1)
+	go func() { done <- fn() }()
+	select {
+	case status := <-done:
+		return status
+	case <-ctx.Done():
+		st.runaway = done
+		setLastError(fmt.Errorf("transaction %s: processing exceeded its time limit", id))
+		return processingTimedOut
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/corazawaf/coraza/v3/experimental/plugins"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
+)
+
+// sleepOperator never matches, after sleeping for its argument, e.g.
+// "@testsleep 100ms"; it stands in for a pathologically slow rule.
+type sleepOperator time.Duration
+
+func (d sleepOperator) Evaluate(plugintypes.TransactionState, string) bool {
+	time.Sleep(time.Duration(d))
+	return false
+}
+
+func init() {
+	plugins.RegisterOperator("testsleep", func(opts plugintypes.OperatorOptions) (plugintypes.Operator, error) {
+		d, err := time.ParseDuration(opts.Arguments)
+		return sleepOperator(d), err
+	})
+}
+
+// TestTimeoutRunaway lets a phase overrun the processing timeout in each phase
+// function, then frees the transaction while its rules are still running.
+// Run it with -race: the background evaluation must not share txState with
+// the caller.
+func TestTimeoutRunaway(t *testing.T) {
+	const textPlain = `[["Content-Type","text/plain"]]`
+	body := []byte("a=b")
+	bodyPtr := unsafe.Pointer(&body[0])
+
+	tests := []struct {
+		name  string
+		phase int
+		// before runs the phases preceding the one that overruns.
+		before func(tx uint64)
+		run    func(tx uint64) int64
+	}{
+		{"request headers", 1, nil, func(tx uint64) int64 {
+			return callInt(coraza_process_request_headers, tx, "GET", "/", "HTTP/1.1", "[]")
+		}},
+		{"raw request headers", 1, nil, func(tx uint64) int64 {
+			return callInt(coraza_process_request_headers_raw, tx, "GET", "/", "HTTP/1.1", nil, 0)
+		}},
+		{"request", 1, nil, func(tx uint64) int64 {
+			return callInt(coraza_process_request, tx, "192.0.2.1", 1234, "192.0.2.2", 80, "GET", "/", "HTTP/1.1", "[]")
+		}},
+		{"request body", 2, requestHeaders, func(tx uint64) int64 {
+			return callInt(coraza_process_request_body, tx, bodyPtr, len(body))
+		}},
+		{"request body JSON", 2, requestHeaders, func(tx uint64) int64 {
+			return callInt(coraza_process_request_body_json, tx, `{"json.a":"b"}`)
+		}},
+		{"response headers", 3, request, func(tx uint64) int64 {
+			return callInt(coraza_process_response_headers, tx, 200, textPlain)
+		}},
+		{"switching protocols", 4, request, func(tx uint64) int64 {
+			return callInt(coraza_process_response_headers, tx, 101, "[]")
+		}},
+		{"response body", 4, func(tx uint64) {
+			request(tx)
+			callInt(coraza_process_response_headers, tx, 200, textPlain)
+		}, func(tx uint64) int64 {
+			return callInt(coraza_process_response_body, tx, bodyPtr, len(body))
+		}},
+		{"response", 4, request, func(tx uint64) int64 {
+			return callInt(coraza_process_response, tx, 200, textPlain, bodyPtr, len(body))
+		}},
+		{"logging", 5, request, func(tx uint64) int64 {
+			return callInt(coraza_finalize, tx, nil)
+		}},
+	}
+
+	callInt(coraza_set_processing_timeout, 20)
+	defer callInt(coraza_set_processing_timeout, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waf := newTestWAF(t, fmt.Sprintf(`SecRuleEngine On
+SecRequestBodyAccess On
+SecResponseBodyAccess On
+SecResponseBodyMimeType text/plain
+SecRule REQUEST_URI "@testsleep 200ms" "id:1,phase:%d,pass,nolog"`, tt.phase))
+			active := activeTransactions.Load()
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			if tt.before != nil {
+				tt.before(tx)
+			}
+			if got := tt.run(tx); got != processingTimedOut {
+				t.Fatalf("status = %d, want %d", got, processingTimedOut)
+			}
+			if got := callInt(coraza_transaction_phase, tx); got != -1 {
+				t.Errorf("runaway transaction still usable: phase = %d", got)
+			}
+			call(coraza_free_transaction, tx)
+
+			// The transaction is released once its rules return.
+			for deadline := time.Now().Add(5 * time.Second); activeTransactions.Load() != active; {
+				if time.Now().After(deadline) {
+					t.Fatal("runaway transaction never released")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func request(tx uint64) {
+	requestHeaders(tx)
+	callInt(coraza_process_request_body, tx, nil, 0)
+}
@@ -0,0 +1,93 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// coraza_process_request_trailers adds HTTP trailer fields, given as a JSON
+// array of [name, value] pairs, to REQUEST_HEADERS. Trailers take part in the
+// request body phase: call this once the body has been received but before
+// coraza_process_request_body so phase 2 rules see them. Trailers added after
+// that are only visible to the response and logging phases. Returns 0 on
+// success or -1 for an unknown handle or malformed JSON.
+//
+//export coraza_process_request_trailers
+func coraza_process_request_trailers(txID C.uint64_t, headersJSON *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return addHeaders(headersJSON, st.addRequestHeader)
+}
+
+// coraza_process_response_trailers adds HTTP trailer fields, given as a JSON
+// array of [name, value] pairs, to RESPONSE_HEADERS. Trailers take part in the
+// response body phase: call this once the body has been written but before
+// coraza_process_response_body so phase 4 rules see them. Returns 0 on success
+// or -1 for an unknown handle or malformed JSON.
+//
+//export coraza_process_response_trailers
+func coraza_process_response_trailers(txID C.uint64_t, headersJSON *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return addHeaders(headersJSON, st.addResponseHeader)
+}
+
+// coraza_add_request_trailer is coraza_process_request_trailers for a single
+// trailer field, for hosts that receive trailers one at a time. Returns 0 on
+// success or -1 for an unknown handle or an empty name.
+//
+//export coraza_add_request_trailer
+func coraza_add_request_trailer(txID C.uint64_t, name, value *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return addHeader(name, value, st.addRequestHeader)
+}
+
+// coraza_add_response_trailer is coraza_process_response_trailers for a
+// single trailer field, such as the grpc-status and grpc-message trailers
+// that carry the outcome of a gRPC call. Returns 0 on success or -1 for an
+// unknown handle or an empty name.
+//
+//export coraza_add_response_trailer
+func coraza_add_response_trailer(txID C.uint64_t, name, value *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return addHeader(name, value, st.addResponseHeader)
+}
+
+// addHeader passes a single header field to add.
+func addHeader(name, value *C.char, add func(key, value string)) C.int {
+	key := C.GoString(name)
+	if key == "" {
+		return -1
+	}
+	add(key, C.GoString(value))
+	return 0
+}
+
+// addHeaders decodes a JSON array of [name, value] pairs and passes each to add.
+func addHeaders(headersJSON *C.char, add func(key, value string)) C.int {
+	var headers [][2]string
+	if err := json.Unmarshal([]byte(C.GoString(headersJSON)), &headers); err != nil {
+		setLastError(fmt.Errorf("invalid headers JSON: %w", err))
+		return -1
+	}
+	for _, h := range headers {
+		add(h[0], h[1])
+	}
+	return 0
+}
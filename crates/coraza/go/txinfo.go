@@ -0,0 +1,99 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+// coraza_transaction_elapsed_us returns the total time, in microseconds, the
+// transaction has spent in WAF processing across all phases, or -1 for an
+// unknown handle.
+//
+//export coraza_transaction_elapsed_us
+func coraza_transaction_elapsed_us(txID C.uint64_t) C.int64_t {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return C.int64_t(st.elapsed().Microseconds())
+}
+
+// coraza_transaction_phase returns the latest phase the transaction has
+// completed: 0 before coraza_process_request_headers, then 1 (request
+// headers), 2 (request body), 3 (response headers), 4 (response body) and 5
+// once coraza_finalize has run the logging phase. Writing a body does not
+// complete its phase. Phases skipped by the host are not counted, so a
+// transaction abandoned after the header phase stays at 1. Returns -1 for an
+// unknown handle.
+//
+//export coraza_transaction_phase
+func coraza_transaction_phase(txID C.uint64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	return C.int(st.phase)
+}
+
+// coraza_matched_rule_count returns the number of rules that have matched so
+// far, or -1 for an unknown handle.
+//
+//export coraza_matched_rule_count
+func coraza_matched_rule_count(txID C.uint64_t) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	return C.int(len(tx.MatchedRules()))
+}
+
+// matchedRule is one element of coraza_get_matched_rules_json.
+type matchedRule struct {
+	RuleID       int      `json:"rule_id"`
+	Phase        int      `json:"phase"`
+	Message      string   `json:"message"`
+	Data         string   `json:"data"`
+	Severity     *int     `json:"severity,omitempty"`
+	SeverityText string   `json:"severityText,omitempty"`
+	Tags         []string `json:"tags"`
+}
+
+// coraza_get_matched_rules_json returns the rules that have matched so far,
+// in match order, as a JSON array of objects with the "rule_id", "phase",
+// "message", logdata ("data"), the "severity" both as its syslog number (0
+// for emergency to 7 for debug, so lower is more severe) and as
+// "severityText" ("emergency", "alert", "critical", "error", "warning",
+// "notice", "info" or "debug"), and the "tags". Both severity fields are
+// omitted for rules without a severity action. Returns "[]" when nothing
+// matched, or nil for an unknown handle. The caller must free the returned
+// string.
+//
+//export coraza_get_matched_rules_json
+func coraza_get_matched_rules_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	tx := st.tx
+
+	rules := []matchedRule{}
+	for _, mr := range tx.MatchedRules() {
+		rule := mr.Rule()
+		tags := rule.Tags()
+		if tags == nil {
+			tags = []string{}
+		}
+		severity, severityText := severityFields(rule)
+		rules = append(rules, matchedRule{
+			RuleID:       rule.ID(),
+			Phase:        int(rule.Phase()),
+			Message:      st.maskMatched(mr, mr.Message()),
+			Data:         st.maskMatched(mr, mr.Data()),
+			Severity:     severity,
+			SeverityText: severityText,
+			Tags:         tags,
+		})
+	}
+	return jsonCString(rules)
+}
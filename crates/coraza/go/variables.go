@@ -0,0 +1,219 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// Argument sources for coraza_get_args_count.
+const (
+	argsAll  = 0 // ARGS: query, body and path arguments
+	argsGet  = 1 // ARGS_GET
+	argsPost = 2 // ARGS_POST
+)
+
+// coraza_get_args_count returns the number of arguments parsed so far from
+// the given source (0 for ARGS, 1 for ARGS_GET, 2 for ARGS_POST), or -1 for an
+// unknown handle or source. Repeated names count once per value.
+//
+//export coraza_get_args_count
+func coraza_get_args_count(txID C.uint64_t, source C.int) C.int {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return -1
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return -1
+	}
+
+	var col collection.Collection
+	switch source {
+	case argsAll:
+		col = vars.Args()
+	case argsGet:
+		col = vars.ArgsGet()
+	case argsPost:
+		col = vars.ArgsPost()
+	default:
+		return -1
+	}
+	return C.int(len(col.FindAll()))
+}
+
+// coraza_get_request_body_processor returns the body processor Coraza
+// selected for the request body, from the Content-Type or a
+// ctl:requestBodyProcessor action: "URLENCODED", "MULTIPART", "JSON" or
+// "XML". It returns "" if the body is not parsed, because no processor was
+// selected or request body access is off, in which case rules only see it as
+// REQUEST_BODY. The selection is final once coraza_process_request_body has
+// run. It returns nil for an unknown handle. The caller must free the
+// returned string.
+//
+//export coraza_get_request_body_processor
+func coraza_get_request_body_processor(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	if !tx.IsRequestBodyAccessible() {
+		return C.CString("")
+	}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return C.CString("")
+	}
+	rbp := ""
+	vars.All(func(v variables.RuleVariable, col collection.Collection) bool {
+		if v != variables.ReqbodyProcessor {
+			return true
+		}
+		if single, ok := col.(collection.Single); ok {
+			rbp = single.Get()
+		}
+		return false
+	})
+	return C.CString(strings.ToUpper(rbp))
+}
+
+// coraza_collection_json returns the contents of the transaction collection
+// name, such as "ARGS", "REQUEST_HEADERS", "REQUEST_COOKIES", "FILES"
+// or "TX" (case-insensitive), as a JSON array of [key, value] pairs sorted by
+// key; keys are empty for single-valued variables like "REQUEST_URI". It returns nil for an
+// unknown handle or collection name. The caller must free the returned string.
+//
+//export coraza_collection_json
+func coraza_collection_json(txID C.uint64_t, name *C.char) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok {
+		return nil
+	}
+	want, err := variables.Parse(C.GoString(name))
+	if err != nil {
+		return nil
+	}
+
+	var pairs [][2]string
+	vars.All(func(v variables.RuleVariable, col collection.Collection) bool {
+		if v != want {
+			return true
+		}
+		pairs = [][2]string{}
+		for _, md := range col.FindAll() {
+			value := md.Value()
+			if st.waf.masks(v, md.Key()) {
+				value = headerMask
+			}
+			pairs = append(pairs, [2]string{md.Key(), value})
+		}
+		return false
+	})
+	if pairs == nil {
+		return nil
+	}
+	// Keyed collections are maps; order them by key for stable output.
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return jsonCString(pairs)
+}
+
+// coraza_get_request_cookies_json returns REQUEST_COOKIES, the cookies as
+// Coraza parsed them from the Cookie header, as a JSON object mapping each
+// name to an array of its values in header order. Values are masked while
+// Cookie is one of the masked headers (see coraza_set_masked_headers), as it
+// is by default. It returns "{}" if there are none, or nil for an unknown
+// handle. The caller must free the returned string.
+//
+//export coraza_get_request_cookies_json
+func coraza_get_request_cookies_json(txID C.uint64_t) *C.char {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return nil
+	}
+	cookies := map[string][]string{}
+	if vars, ok := txVariables(st.tx); ok {
+		for _, md := range vars.RequestCookies().FindAll() {
+			value := md.Value()
+			if st.waf.masks(variables.RequestCookies, md.Key()) {
+				value = headerMask
+			}
+			cookies[md.Key()] = append(cookies[md.Key()], value)
+		}
+	}
+	return jsonCString(cookies)
+}
+
+// uploadedFile is one element of coraza_get_files_json.
+type uploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// coraza_get_files_json returns the files uploaded in a multipart request
+// body as a JSON array of objects with the form "field", the client-supplied
+// "filename", the "size" in bytes and the part's "content_type" if it had
+// one, in body order. It returns "[]" if there are none, including before the
+// request body has been processed, or nil for an unknown handle. The caller
+// must free the returned string.
+//
+//export coraza_get_files_json
+func coraza_get_files_json(txID C.uint64_t) *C.char {
+	tx, ok := lookupTx(txID)
+	if !ok {
+		return nil
+	}
+	files := []uploadedFile{}
+	vars, ok := txVariables(tx)
+	if !ok {
+		return jsonCString(files)
+	}
+
+	// FILES and FILES_NAMES hold one entry per file part, in order; sizes are
+	// keyed by filename and part headers by field name.
+	names := vars.FilesNames().Get("")
+	seen := map[string]int{}
+	for i, filename := range vars.Files().Get("") {
+		f := uploadedFile{Filename: filename}
+		if i < len(names) {
+			f.Field = names[i]
+		}
+		if sz := vars.FilesSizes().Get(filename); len(sz) > 0 {
+			f.Size, _ = strconv.ParseInt(sz[0], 10, 64)
+		}
+		f.ContentType = partContentType(vars.MultipartPartHeaders().Get(f.Field), seen[f.Field])
+		seen[f.Field]++
+		files = append(files, f)
+	}
+	return jsonCString(files)
+}
+
+// partContentType returns the nth Content-Type among a field's part headers,
+// which MULTIPART_PART_HEADERS stores as "Name: value".
+func partContentType(headers []string, n int) string {
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || !strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		if n == 0 {
+			return strings.TrimSpace(value)
+		}
+		n--
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// sampleRequest is a synthetic request, as passed to coraza_warmup and
+// coraza_test_rule.
+type sampleRequest struct {
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Protocol string      `json:"protocol"`
+	Headers  [][2]string `json:"headers"`
+	Body     string      `json:"body"`
+
+	// The response defaults to an empty 200 text/html one.
+	ResponseStatus  int         `json:"response_status"`
+	ResponseHeaders [][2]string `json:"response_headers"`
+	ResponseBody    string      `json:"response_body"`
+}
+
+// defaultWarmupRequests are used when coraza_warmup is given no samples.
+var defaultWarmupRequests = []sampleRequest{
+	{Method: "GET", URI: "/?q=warmup&id=1", Headers: [][2]string{
+		{"Host", "localhost"}, {"User-Agent", "coraza-warmup"}, {"Accept", "*/*"},
+	}},
+	{Method: "POST", URI: "/login", Headers: [][2]string{
+		{"Host", "localhost"}, {"User-Agent", "coraza-warmup"},
+		{"Content-Type", "application/x-www-form-urlencoded"},
+	}, Body: "user=warmup&pass=warmup"},
+	{Method: "POST", URI: "/api", Headers: [][2]string{
+		{"Host", "localhost"}, {"User-Agent", "coraza-warmup"},
+		{"Content-Type", "application/json"},
+	}, Body: `{"warmup":true,"items":[1,2,3]}`},
+}
+
+// coraza_warmup runs synthetic transactions through the request and response
+// phases of a WAF so that regexes, transformation caches and pools are primed
+// before real traffic arrives. sampleRequestsJSON is a JSON array of objects
+// with "method", "uri", "protocol", "headers" ([name, value] pairs) and
+// "body"; nil or an empty array uses a small built-in set. Results are
+// discarded: the transactions are never registered, the logging phase is not
+// run and no persistent collection is written. Returns the time taken in
+// microseconds, or -1 for an unknown WAF or malformed JSON.
+//
+//export coraza_warmup
+func coraza_warmup(wafID C.uint64_t, sampleRequestsJSON *C.char) C.int64_t {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return -1
+	}
+
+	var samples []sampleRequest
+	if sampleRequestsJSON != nil {
+		if err := json.Unmarshal([]byte(C.GoString(sampleRequestsJSON)), &samples); err != nil {
+			setLastError(fmt.Errorf("invalid sample requests JSON: %w", err))
+			return -1
+		}
+	}
+	if len(samples) == 0 {
+		samples = defaultWarmupRequests
+	}
+
+	start := time.Now()
+	for _, r := range samples {
+		warmupTransaction(ws, r)
+	}
+	return C.int64_t(time.Since(start).Microseconds())
+}
+
+// coraza_warmup_waf is coraza_warmup with the built-in sample requests, to
+// call right after creating a WAF, e.g. on a configuration push, so the
+// first real request does not pay for priming it. Regexes are compiled when
+// the rules are loaded; what the first transaction would otherwise pay for
+// is allocating the transaction pool and the caches of the rules it runs.
+// Returns 0 on success or -1 for an unknown WAF.
+//
+//export coraza_warmup_waf
+func coraza_warmup_waf(wafID C.uint64_t) C.int {
+	if coraza_warmup(wafID, nil) < 0 {
+		return -1
+	}
+	return 0
+}
+
+func warmupTransaction(ws *wafState, r sampleRequest) {
+	tx := ws.engine().NewTransaction()
+	defer tx.Close()
+	r.run(tx)
+}
+
+// run processes the request, and a response to it, through every phase but
+// logging, stopping at the first interruption.
+func (r sampleRequest) run(tx types.Transaction) {
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	if r.URI == "" {
+		r.URI = "/"
+	}
+	if r.Protocol == "" {
+		r.Protocol = "HTTP/1.1"
+	}
+	tx.ProcessURI(r.URI, r.Method, r.Protocol)
+	for _, h := range r.Headers {
+		tx.AddRequestHeader(h[0], h[1])
+	}
+	if it := tx.ProcessRequestHeaders(); it != nil {
+		return
+	}
+	if r.Body != "" {
+		if it, _, err := tx.WriteRequestBody([]byte(r.Body)); it != nil || err != nil {
+			return
+		}
+	}
+	if it, err := tx.ProcessRequestBody(); it != nil || err != nil {
+		return
+	}
+
+	if r.ResponseStatus == 0 {
+		r.ResponseStatus = 200
+	}
+	if r.ResponseHeaders == nil {
+		r.ResponseHeaders = [][2]string{{"Content-Type", "text/html"}}
+	}
+	for _, h := range r.ResponseHeaders {
+		tx.AddResponseHeader(h[0], h[1])
+	}
+	if it := tx.ProcessResponseHeaders(r.ResponseStatus, r.Protocol); it != nil {
+		return
+	}
+	if r.ResponseBody != "" && tx.IsResponseBodyAccessible() {
+		if it, _, err := tx.WriteResponseBody([]byte(r.ResponseBody)); it != nil || err != nil {
+			return
+		}
+	}
+	tx.ProcessResponseBody()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// warmupBenchRules exercise transformations, regexes and phrase matching
+// across the request phases, as a small CRS-like rule set would.
+const warmupBenchRules = `SecRuleEngine On
+SecRequestBodyAccess On
+SecRule REQUEST_HEADERS:User-Agent "@pm nikto sqlmap nessus" "id:1,phase:1,deny,status:403,t:lowercase"
+SecRule ARGS "@rx (?i)(?:union\s+select|select\s+.+\s+from)" "id:2,phase:2,deny,status:403,t:urlDecodeUni,t:compressWhitespace"
+SecRule ARGS "@rx (?i)<script[^>]*>" "id:3,phase:2,deny,status:403,t:htmlEntityDecode,t:lowercase"
+SecRule REQUEST_FILENAME "@rx \.(?:bak|old|sql)$" "id:4,phase:1,deny,status:404,t:normalizePath"`
+
+// BenchmarkFirstRequest measures the first transaction of a freshly created
+// WAF, with and without coraza_warmup; only that transaction is timed.
+func BenchmarkFirstRequest(b *testing.B) {
+	body := []byte("user=alice&comment=hello+world")
+	for _, bm := range []struct {
+		name string
+		warm bool
+	}{{"cold", false}, {"warm", true}} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				waf := uint64(callInt(coraza_new_waf, warmupBenchRules))
+				if waf == 0 {
+					b.Fatalf("coraza_new_waf: %s", lastError)
+				}
+				if bm.warm && callInt(coraza_warmup, waf, nil) < 0 {
+					b.Fatalf("coraza_warmup: %s", lastError)
+				}
+				b.StartTimer()
+
+				tx := uint64(callInt(coraza_new_transaction, waf))
+				callInt(coraza_process_request_headers, tx, "POST", "/comments?page=2", "HTTP/1.1",
+					`[["Host","example.com"],["User-Agent","Mozilla/5.0"],["Content-Type","application/x-www-form-urlencoded"]]`)
+				callInt(coraza_process_request_body, tx, unsafe.Pointer(&body[0]), len(body))
+				call(coraza_free_transaction, tx)
+
+				b.StopTimer()
+				call(coraza_free_waf, waf)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// TestWarmup checks that warmup transactions are discarded rather than
+// registered, and that bad input is rejected.
+func TestWarmup(t *testing.T) {
+	waf := newTestWAF(t, warmupBenchRules)
+	live := func() (n int) {
+		txInstances.Range(func(any, any) bool { n++; return true })
+		return n
+	}
+	before := live()
+	for _, samples := range []any{nil, `[]`, `[{"uri":"/?q=union+select+1+from+t","headers":[["User-Agent","sqlmap"]]}]`} {
+		if got := callInt(coraza_warmup, waf, samples); got < 0 {
+			t.Errorf("coraza_warmup(%v) = %d: %s", samples, got, lastError)
+		}
+	}
+	if after := live(); after != before {
+		t.Errorf("%d transactions registered by warmup", after-before)
+	}
+
+	if got := callInt(coraza_warmup, waf, `{"uri":"/"}`); got != -1 {
+		t.Errorf("coraza_warmup with malformed JSON = %d, want -1", got)
+	}
+	if got := callInt(coraza_warmup, 0, nil); got != -1 {
+		t.Errorf("coraza_warmup of an unknown WAF = %d, want -1", got)
+	}
+}
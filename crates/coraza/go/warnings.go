@@ -0,0 +1,151 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/corazawaf/coraza/v3/debuglog"
+)
+
+// warningRecorder collects the warnings and errors Coraza logs while a WAF is
+// being built, including rules skipped under SecIgnoreRuleCompilationErrors.
+// Coraza otherwise discards them, since its debug logger is a no-op unless
+// configured.
+type warningRecorder struct {
+	mu       sync.Mutex
+	warnings []string
+
+	// stopped is set once the WAF is built. levelSet records that the
+	// ruleset chose its own SecDebugLogLevel, and level is the level written
+	// to its SecDebugLog output.
+	stopped  atomic.Bool
+	levelSet atomic.Bool
+	level    atomic.Int32
+}
+
+// ignoredRuleMsg is the debug message Coraza logs for a rule dropped under
+// SecIgnoreRuleCompilationErrors, e.g. one with a duplicate ID.
+const ignoredRuleMsg = "Ignoring rule compilation error"
+
+// logger returns a debug logger that records into r. It writes nothing until
+// the ruleset points it somewhere with SecDebugLog, so the WAF behaves as with
+// Coraza's default logger.
+func (r *warningRecorder) logger() debuglog.Logger {
+	r.level.Store(int32(debuglog.LevelWarn))
+	return recordingLogger{
+		Logger: debuglog.DefaultWithPrinterFactory(r.printer).
+			WithOutput(io.Discard).
+			WithLevel(debuglog.LevelDebug),
+		r: r,
+	}
+}
+
+func (r *warningRecorder) printer(w io.Writer) debuglog.Printer {
+	var out *log.Logger
+	if w != io.Discard {
+		out = log.New(w, "", log.LstdFlags)
+	}
+	return func(lvl debuglog.Level, message, fields string) {
+		if lvl <= debuglog.LevelWarn || message == ignoredRuleMsg {
+			r.record(strings.Join(strings.Fields(message+" "+fields), " "))
+		}
+		if out != nil && int32(lvl) <= r.level.Load() {
+			out.Printf("[%s] %s %s", lvl.String(), message, fields)
+		}
+	}
+}
+
+func (r *warningRecorder) record(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped.Load() {
+		r.warnings = append(r.warnings, msg)
+	}
+}
+
+// stop ends recording, so warnings logged by transactions are not mixed in
+// with load-time ones, and returns what was recorded.
+func (r *warningRecorder) stop() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped.Store(true)
+	return r.warnings
+}
+
+// quiet reports whether below-warning events should be dropped: once loading
+// is done, unless the ruleset set a level itself.
+func (r *warningRecorder) quiet() bool {
+	return r.stopped.Load() && !r.levelSet.Load()
+}
+
+// recordingLogger keeps the debug level open while the ruleset loads, so
+// ignored rule errors are seen, and drops to warnings afterwards.
+type recordingLogger struct {
+	debuglog.Logger
+	r *warningRecorder
+}
+
+func (l recordingLogger) WithOutput(w io.Writer) debuglog.Logger {
+	return recordingLogger{Logger: l.Logger.WithOutput(w), r: l.r}
+}
+
+func (l recordingLogger) WithLevel(lvl debuglog.Level) debuglog.Logger {
+	l.r.levelSet.Store(true)
+	l.r.level.Store(int32(lvl))
+	return recordingLogger{Logger: l.Logger.WithLevel(lvl), r: l.r}
+}
+
+func (l recordingLogger) With(fs ...debuglog.ContextField) debuglog.Logger {
+	return recordingLogger{Logger: l.Logger.With(fs...), r: l.r}
+}
+
+func (l recordingLogger) Trace() debuglog.Event {
+	if l.r.quiet() {
+		return debuglog.Noop().Trace()
+	}
+	return l.Logger.Trace()
+}
+
+func (l recordingLogger) Debug() debuglog.Event {
+	if l.r.quiet() {
+		return debuglog.Noop().Debug()
+	}
+	return l.Logger.Debug()
+}
+
+func (l recordingLogger) Info() debuglog.Event {
+	if l.r.quiet() {
+		return debuglog.Noop().Info()
+	}
+	return l.Logger.Info()
+}
+
+// coraza_waf_warnings_json returns the non-fatal warnings and ignored errors
+// logged while the WAF's current ruleset was loaded, including by
+// coraza_add_rule, as a JSON array of strings, or nil for an unknown WAF.
+// Fatal problems, including duplicate rule IDs unless
+// SecIgnoreRuleCompilationErrors is on, fail WAF creation instead and are
+// reported by coraza_last_error. The caller must free the returned string.
+//
+//export coraza_waf_warnings_json
+func coraza_waf_warnings_json(wafID C.uint64_t) *C.char {
+	ws, ok := lookupWAF(wafID)
+	if !ok {
+		return nil
+	}
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	if ws.warnings == nil {
+		return C.CString("[]")
+	}
+	return jsonCString(ws.warnings)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWarningsAfterAddRule(t *testing.T) {
+	waf := newTestWAF(t, `SecRuleEngine On
+SecIgnoreRuleCompilationErrors On
+SecRule ARGS "@rx a" "id:1,phase:1,pass"`)
+	if got, _ := callString(coraza_waf_warnings_json, waf); strings.Contains(got, ignoredRuleMsg) {
+		t.Fatalf("warnings = %s, want no ignored rule", got)
+	}
+	if got := callInt(coraza_add_rule, waf, `SecRule ARGS "@rx b" "id:1,phase:1,pass"`, nil); got != 0 {
+		t.Fatalf("coraza_add_rule = %d: %s", got, lastError)
+	}
+	if got, _ := callString(coraza_waf_warnings_json, waf); !strings.Contains(got, ignoredRuleMsg) {
+		t.Errorf("warnings = %s, want the ignored duplicate rule", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"strings"
+
+	"github.com/corazawaf/coraza/v3/collection"
+	"github.com/corazawaf/coraza/v3/types"
+)
+
+// coraza_is_websocket_upgrade returns 1 if the request asked for a WebSocket
+// upgrade (Connection: Upgrade and Upgrade: websocket) and, once response
+// headers have been processed, the response switched protocols; 0 otherwise,
+// or -1 for an unknown handle.
+//
+//export coraza_is_websocket_upgrade
+func coraza_is_websocket_upgrade(txID C.uint64_t) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	vars, ok := txVariables(st.tx)
+	if !ok || !isWebSocketUpgrade(vars.RequestHeaders()) {
+		return 0
+	}
+	if status := vars.ResponseStatus().Get(); status != "" && !st.upgraded {
+		return 0
+	}
+	return 1
+}
+
+// isBodilessUpgrade reports whether tx is a WebSocket handshake that
+// declares no request body.
+func isBodilessUpgrade(tx types.Transaction) bool {
+	vars, ok := txVariables(tx)
+	if !ok || !isWebSocketUpgrade(vars.RequestHeaders()) {
+		return false
+	}
+	if len(vars.RequestHeaders().Get("transfer-encoding")) > 0 {
+		return false
+	}
+	for _, v := range vars.RequestHeaders().Get("content-length") {
+		if strings.TrimSpace(v) != "0" {
+			return false
+		}
+	}
+	return true
+}
+
+// isWebSocketUpgrade reports whether request headers ask for a WebSocket
+// upgrade.
+func isWebSocketUpgrade(headers collection.Map) bool {
+	upgrade := false
+	for _, v := range headers.Get("upgrade") {
+		if strings.EqualFold(strings.TrimSpace(v), "websocket") {
+			upgrade = true
+		}
+	}
+	if !upgrade {
+		return false
+	}
+	for _, v := range headers.Get("connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
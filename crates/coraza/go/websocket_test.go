@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWebSocketUpgrade(t *testing.T) {
+	const handshake = `["Connection","keep-alive, Upgrade"],["Upgrade","WebSocket"],["Sec-WebSocket-Version","13"]`
+	tests := []struct {
+		name    string
+		method  string
+		headers string
+		// wantUpgrade is coraza_is_websocket_upgrade after the request
+		// headers; wantRead is coraza_should_read_request_body.
+		wantUpgrade int64
+		wantRead    int64
+	}{
+		{"plain request", "GET", `[]`, 0, 1},
+		{"handshake", "GET", "[" + handshake + "]", 1, 0},
+		{"handshake with empty body", "GET", "[" + handshake + `,["Content-Length","0"]]`, 1, 0},
+		{"handshake with body", "POST", "[" + handshake + `,["Content-Length","5"]]`, 1, 1},
+		{"handshake with chunked body", "POST", "[" + handshake + `,["Transfer-Encoding","chunked"]]`, 1, 1},
+		{"upgrade without connection token", "GET", `[["Connection","keep-alive"],["Upgrade","websocket"]]`, 0, 1},
+		{"other protocol", "GET", `[["Connection","Upgrade"],["Upgrade","h2c"]]`, 0, 1},
+	}
+
+	waf := newTestWAF(t, "SecRuleEngine On\nSecRequestBodyAccess On")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := uint64(callInt(coraza_new_transaction, waf))
+			defer call(coraza_free_transaction, tx)
+			callInt(coraza_process_request_headers, tx, tt.method, "/ws", "HTTP/1.1", tt.headers)
+			if got := callInt(coraza_is_websocket_upgrade, tx); got != tt.wantUpgrade {
+				t.Errorf("coraza_is_websocket_upgrade = %d, want %d", got, tt.wantUpgrade)
+			}
+			if got := callInt(coraza_should_read_request_body, tx); got != tt.wantRead {
+				t.Errorf("coraza_should_read_request_body = %d, want %d", got, tt.wantRead)
+			}
+		})
+	}
+
+	for status, want := range map[int]int64{101: 1, 200: 0, 426: 0} {
+		tx := uint64(callInt(coraza_new_transaction, waf))
+		callInt(coraza_process_request_headers, tx, "GET", "/ws", "HTTP/1.1", "["+handshake+"]")
+		callInt(coraza_process_request_body, tx, nil, 0)
+		callInt(coraza_process_response_headers, tx, status, "[]")
+		if got := callInt(coraza_is_websocket_upgrade, tx); got != want {
+			t.Errorf("coraza_is_websocket_upgrade after %d = %d, want %d", status, got, want)
+		}
+		call(coraza_free_transaction, tx)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// coraza_set_trusted_hops sets how many proxies in front of this WAF's host
+// are trusted to append to X-Forwarded-For, for
+// coraza_process_connection_xff: 0 (the default) ignores the header, 1
+// trusts the peer connecting to the host (e.g. a load balancer), and so on.
+// Returns 0 on success or -1 for an unknown WAF or a negative count.
+//
+//export coraza_set_trusted_hops
+func coraza_set_trusted_hops(wafID C.uint64_t, hops C.int) C.int {
+	ws, ok := lookupWAF(wafID)
+	if !ok || hops < 0 {
+		return -1
+	}
+	ws.trustedHops.Store(int32(hops))
+	return 0
+}
+
+// coraza_process_connection_xff is coraza_process_connection for a host
+// behind proxies. xff is the X-Forwarded-For value, NULL or "" if there was
+// none, and remoteAddr the address of the peer. REMOTE_ADDR is set to the
+// address reached by skipping the WAF's trusted hops (see
+// coraza_set_trusted_hops) from the right of the header's entries followed by
+// the peer; an entry that is not an IP address stops the walk. REMOTE_PORT is
+// the peer's port when the peer is the client, else 0. Returns 0 on success
+// or -1 for an unknown handle or a remoteAddr that is not an IP address.
+//
+//export coraza_process_connection_xff
+func coraza_process_connection_xff(txID C.uint64_t, xff, remoteAddr *C.char) C.int {
+	st, ok := lookupTxState(txID)
+	if !ok {
+		return -1
+	}
+	peer, peerPort, ok := parseHostPort(C.GoString(remoteAddr))
+	if !ok {
+		setLastError(errors.New("invalid remote address"))
+		return -1
+	}
+
+	client, port := peer, peerPort
+	entries := strings.Split(C.GoString(xff), ",")
+	for hops := int(st.waf.trustedHops.Load()); hops > 0 && len(entries) > 0; hops-- {
+		ip, _, ok := parseHostPort(entries[len(entries)-1])
+		entries = entries[:len(entries)-1]
+		if !ok {
+			break
+		}
+		client, port = ip, 0
+	}
+
+	serverIP, serverPort := "", 0
+	if vars, ok := txVariables(st.tx); ok {
+		serverIP = vars.ServerAddr().Get()
+		serverPort, _ = strconv.Atoi(vars.ServerPort().Get())
+	}
+	st.tx.ProcessConnection(client, port, serverIP, serverPort)
+	return 0
+}
+
+// parseHostPort parses an IP address with an optional port, as in
+// "192.0.2.1", "192.0.2.1:8080", "2001:db8::1" or "[2001:db8::1]:8080".
+func parseHostPort(s string) (string, int, bool) {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String(), 0, true
+	}
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", 0, false
+	}
+	ip := net.ParseIP(host)
+	port, err := strconv.Atoi(portStr)
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return "", 0, false
+	}
+	return ip.String(), port, true
+}
@@ -1,8 +1,62 @@
 use std::os::raw::{c_char, c_int, c_void};
 
+/// The result written by `coraza_result_packed`.
+#[repr(C)]
+#[derive(Clone, Copy, Debug, Default)]
+pub struct CorazaResult {
+    pub status: i32,
+    pub rule_id: i32,
+    pub matched_rule_count: i32,
+    pub max_severity: i32,
+}
+
 extern "C" {
     pub fn coraza_new_waf(directives: *const c_char) -> u64;
+    pub fn coraza_new_waf_with_options(directives: *const c_char, options_json: *const c_char) -> u64;
+    pub fn coraza_set_tmp_dir(waf_id: u64, path: *const c_char) -> c_int;
+    pub fn coraza_new_waf_labeled(directives: *const c_char, label: *const c_char) -> u64;
+    pub fn coraza_new_waf_from_files(paths_json: *const c_char) -> u64;
+    pub fn coraza_new_waf_dryrun(directives: *const c_char) -> u64;
+    pub fn coraza_add_rule(
+        waf_id: u64,
+        rule_directive: *const c_char,
+        err_out: *mut *mut c_char,
+    ) -> c_int;
+    pub fn coraza_set_engine_mode(waf_id: u64, mode: *const c_char) -> c_int;
+    pub fn coraza_set_enforcement(waf_id: u64, on: c_int) -> c_int;
+    pub fn coraza_snapshot_stats_json() -> *mut c_char;
+    pub fn coraza_flush_pools() -> c_int;
+    pub fn coraza_get_block_counters_json() -> *mut c_char;
+    pub fn coraza_list_wafs_json() -> *mut c_char;
+    pub fn coraza_get_waf_info_json(waf_id: u64) -> *mut c_char;
+    pub fn coraza_last_error() -> *mut c_char;
+    pub fn coraza_waf_warnings_json(waf_id: u64) -> *mut c_char;
+    pub fn coraza_set_fail_mode(mode: c_int) -> c_int;
+    pub fn coraza_set_processing_timeout(ms: c_int) -> c_int;
+    pub fn coraza_test_rule(
+        rule: *const c_char,
+        input_json: *const c_char,
+        result_out: *mut *mut c_char,
+    ) -> c_int;
     pub fn coraza_new_transaction(waf_id: u64) -> u64;
+    pub fn coraza_new_transactions(waf_id: u64, n: c_int, out: *mut u64) -> c_int;
+    pub fn coraza_warmup(waf_id: u64, sample_requests_json: *const c_char) -> i64;
+    pub fn coraza_warmup_waf(waf_id: u64) -> c_int;
+    pub fn coraza_disable_rule_for_tx(tx_id: u64, rule_id: c_int) -> c_int;
+    pub fn coraza_set_uri_raw(tx_id: u64, raw: c_int) -> c_int;
+    pub fn coraza_process_connection(
+        tx_id: u64,
+        client_ip: *const c_char,
+        client_port: c_int,
+        server_ip: *const c_char,
+        server_port: c_int,
+    ) -> c_int;
+    pub fn coraza_process_connection_xff(
+        tx_id: u64,
+        xff: *const c_char,
+        remote_addr: *const c_char,
+    ) -> c_int;
+    pub fn coraza_remote_addr(tx_id: u64) -> *mut c_char;
     pub fn coraza_process_request_headers(
         tx_id: u64,
         method: *const c_char,
@@ -10,19 +64,145 @@ extern "C" {
         protocol: *const c_char,
         headers_json: *const c_char,
     ) -> c_int;
+    pub fn coraza_process_request(
+        tx_id: u64,
+        client_ip: *const c_char,
+        client_port: c_int,
+        server_ip: *const c_char,
+        server_port: c_int,
+        method: *const c_char,
+        uri: *const c_char,
+        protocol: *const c_char,
+        headers_json: *const c_char,
+    ) -> c_int;
+    pub fn coraza_check_header(waf_id: u64, name: *const c_char, value: *const c_char) -> c_int;
+    pub fn coraza_process_request_headers_raw(
+        tx_id: u64,
+        method: *const c_char,
+        uri: *const c_char,
+        protocol: *const c_char,
+        raw_headers: *const c_void,
+        raw_len: c_int,
+    ) -> c_int;
+    pub fn coraza_set_transaction_deadline(tx_id: u64, unix_millis: i64) -> c_int;
+    pub fn coraza_set_request_body_limit(tx_id: u64, limit: i64) -> c_int;
+    pub fn coraza_set_response_body_limit(tx_id: u64, limit: i64) -> c_int;
+    pub fn coraza_set_force_request_body_inspection(tx_id: u64, on: c_int) -> c_int;
+    pub fn coraza_should_read_request_body(tx_id: u64) -> c_int;
+    pub fn coraza_write_request_body(tx_id: u64, body: *const c_void, body_len: c_int) -> c_int;
     pub fn coraza_process_request_body(tx_id: u64, body: *const c_void, body_len: c_int) -> c_int;
+    pub fn coraza_process_request_body_json(tx_id: u64, flattened_json: *const c_char) -> c_int;
     pub fn coraza_process_response_headers(
         tx_id: u64,
         status_code: c_int,
         headers_json: *const c_char,
     ) -> c_int;
+    pub fn coraza_is_websocket_upgrade(tx_id: u64) -> c_int;
+    pub fn coraza_add_response_mime_type(waf_id: u64, mime: *const c_char) -> c_int;
+    pub fn coraza_set_response_content_encoding(tx_id: u64, encoding: *const c_char) -> c_int;
+    pub fn coraza_set_response_streaming(tx_id: u64, on: c_int) -> c_int;
+    pub fn coraza_write_response_body(tx_id: u64, body: *const c_void, body_len: c_int) -> c_int;
     pub fn coraza_process_response_body(
         tx_id: u64,
         body: *const c_void,
         body_len: c_int,
     ) -> c_int;
+    pub fn coraza_process_response(
+        tx_id: u64,
+        status_code: c_int,
+        headers_json: *const c_char,
+        body: *const c_void,
+        body_len: c_int,
+    ) -> c_int;
+    pub fn coraza_process_response_body_typed(
+        tx_id: u64,
+        content_type: *const c_char,
+        body: *const c_void,
+        body_len: c_int,
+    ) -> c_int;
+    pub fn coraza_process_request_trailers(tx_id: u64, headers_json: *const c_char) -> c_int;
+    pub fn coraza_process_response_trailers(tx_id: u64, headers_json: *const c_char) -> c_int;
+    pub fn coraza_add_request_trailer(tx_id: u64, name: *const c_char, value: *const c_char) -> c_int;
+    pub fn coraza_add_response_trailer(tx_id: u64, name: *const c_char, value: *const c_char) -> c_int;
+    pub fn coraza_request_body_bytes(tx_id: u64, out: *mut c_void, max_len: c_int) -> c_int;
+    pub fn coraza_response_body_bytes(tx_id: u64, out: *mut c_void, max_len: c_int) -> c_int;
+    pub fn coraza_set_capture_on_block(waf_id: u64, enabled: c_int) -> c_int;
+    pub fn coraza_set_capture_request_body(tx_id: u64, enabled: c_int) -> c_int;
+    pub fn coraza_get_full_request_body(tx_id: u64, out: *mut c_void, max_len: c_int) -> c_int;
+    pub fn coraza_get_full_response_body(tx_id: u64, out: *mut c_void, max_len: c_int) -> c_int;
+    pub fn coraza_transaction_elapsed_us(tx_id: u64) -> i64;
+    pub fn coraza_transaction_phase(tx_id: u64) -> c_int;
+    pub fn coraza_matched_rule_count(tx_id: u64) -> c_int;
+    pub fn coraza_get_matched_rules_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_args_count(tx_id: u64, source: c_int) -> c_int;
+    pub fn coraza_get_files_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_uri_info_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_request_body_processor(tx_id: u64) -> *mut c_char;
+    pub fn coraza_request_fingerprint(tx_id: u64) -> *mut c_char;
+    pub fn coraza_collection_json(tx_id: u64, name: *const c_char) -> *mut c_char;
+    pub fn coraza_get_request_cookies_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_geo_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_all_interventions_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_response_interruption_json(tx_id: u64) -> *mut c_char;
     pub fn coraza_intervention_status(tx_id: u64) -> c_int;
+    pub fn coraza_set_block_status(waf_id: u64, default_status: c_int) -> c_int;
+    pub fn coraza_set_block_status_for_tag(waf_id: u64, tag: *const c_char, status: c_int) -> c_int;
+    pub fn coraza_set_action_override(waf_id: u64, from: *const c_char, to: *const c_char) -> c_int;
+    pub fn coraza_set_arg_limits(waf_id: u64, max_args: c_int, max_total_len: i64) -> c_int;
+    pub fn coraza_set_allowed_methods(waf_id: u64, methods_json: *const c_char) -> c_int;
+    pub fn coraza_set_header_limits(waf_id: u64, max_count: c_int, max_total_bytes: i64) -> c_int;
+    pub fn coraza_set_geoip_db(waf_id: u64, path: *const c_char) -> c_int;
+    pub fn coraza_set_trusted_hops(waf_id: u64, hops: c_int) -> c_int;
+    pub fn coraza_is_disruptive(tx_id: u64) -> c_int;
     pub fn coraza_intervention_url(tx_id: u64) -> *mut c_char;
+    pub fn coraza_redirect_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_set_block_page_template(waf_id: u64, template: *const c_char) -> c_int;
+    pub fn coraza_get_block_page_html(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_block_response_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_decisive_rule_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_matched_operators_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_matched_rules_cef(tx_id: u64) -> *mut c_char;
+    pub fn coraza_paranoia_level(waf_id: u64) -> c_int;
+    pub fn coraza_default_action(waf_id: u64) -> *mut c_char;
+    pub fn coraza_rule_phase(waf_id: u64, rule_id: c_int) -> c_int;
+    pub fn coraza_get_rule_metadata_json(waf_id: u64, rule_id: c_int) -> *mut c_char;
+    pub fn coraza_has_response_rules(waf_id: u64) -> c_int;
+    pub fn coraza_get_ruleset_hash(waf_id: u64) -> *mut c_char;
+    pub fn coraza_get_anomaly_scores_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_score_contributors_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_transaction_summary_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_result_packed(tx_id: u64, out: *mut c_void, out_len: c_int) -> c_int;
+    pub fn coraza_set_outbound_anomaly_threshold(tx_id: u64, threshold: c_int) -> c_int;
+    pub fn coraza_set_session_id(tx_id: u64, session_id: *const c_char) -> c_int;
+    pub fn coraza_set_client_classification_var(waf_id: u64, name: *const c_char) -> c_int;
+    pub fn coraza_client_classification(tx_id: u64) -> *mut c_char;
+    pub fn coraza_get_session_var(tx_id: u64, name: *const c_char) -> *mut c_char;
+    pub fn coraza_set_session_var(tx_id: u64, name: *const c_char, value: *const c_char) -> c_int;
+    pub fn coraza_collection_keys_json(waf_id: u64, collection: *const c_char) -> *mut c_char;
+    pub fn coraza_collection_clear(
+        waf_id: u64,
+        collection: *const c_char,
+        key: *const c_char,
+    ) -> c_int;
+    pub fn coraza_export_collections(waf_id: u64, out: *mut *mut c_char) -> c_int;
+    pub fn coraza_import_collections(waf_id: u64, input: *const c_char) -> c_int;
+    pub fn coraza_set_audit_log_format(waf_id: u64, format: *const c_char) -> c_int;
+    pub fn coraza_set_masked_headers(waf_id: u64, names_json: *const c_char) -> c_int;
+    pub fn coraza_set_tx_context(tx_id: u64, key: *const c_char, value: *const c_char) -> c_int;
+    pub fn coraza_will_audit(tx_id: u64) -> c_int;
+    pub fn coraza_audit_log_json(tx_id: u64) -> *mut c_char;
+    pub fn coraza_finalize(tx_id: u64, blocked_out: *mut c_int) -> c_int;
+    pub fn coraza_set_match_callback(
+        tx_id: u64,
+        cb: Option<extern "C" fn(tx_id: u64, rule_id: c_int, message: *const c_char) -> c_int>,
+    ) -> c_int;
+    pub fn coraza_set_body_interruption_callback(
+        cb: Option<extern "C" fn(tx_id: u64, status: c_int)>,
+    );
+    pub fn coraza_set_reap_callback(cb: Option<extern "C" fn(tx_id: u64, age_ms: i64)>);
+    pub fn coraza_set_error_callback(cb: Option<extern "C" fn(message: *const c_char)>);
+    pub fn coraza_sweep_stale_transactions(max_age_ms: i64) -> c_int;
     pub fn coraza_free_transaction(tx_id: u64);
+    pub fn coraza_free_transactions(ids: *const u64, n: c_int);
     pub fn coraza_free_waf(waf_id: u64);
 }
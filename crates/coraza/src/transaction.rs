@@ -189,3 +189,25 @@ extern "C" {
     #[link_name = "free"]
     fn libc_free(ptr: *mut c_void);
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const BLOCKING_RULES: &str = r#"SecRuleEngine On
+SecRule ARGS:attack "@rx ." "id:1,phase:1,deny,status:403,log""#;
+
+    #[test]
+    fn test_check_intervention_with_enforcement_off() {
+        let engine = WafEngine::new(BLOCKING_RULES).unwrap();
+        let tx = WafTransaction::new(&engine);
+        assert_eq!(unsafe { ffi::coraza_set_enforcement(engine.waf_id, 0) }, 0);
+
+        let action = tx.process_request_headers("GET", "/?attack=1", "HTTP/1.1", &[]);
+        assert_eq!(action, WafAction::Pass);
+        assert_eq!(tx.check_intervention(), WafAction::Pass);
+
+        assert_eq!(unsafe { ffi::coraza_set_enforcement(engine.waf_id, 1) }, 0);
+        assert_eq!(tx.check_intervention(), WafAction::Block { status: 403 });
+    }
+}
@@ -0,0 +1,181 @@
+/*
+ * Smoke test for the Coraza bridge C ABI. Compiled against the header that
+ * `go build -buildmode=c-shared` generates, so a signature that no longer
+ * matches the Go exports fails to build.
+ */
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "libcoraza_bridge.h"
+
+static int failures = 0;
+
+static void check(const char *desc, long long expected, long long actual)
+{
+    if (expected == actual) {
+        printf("  PASS: %s (got %lld)\n", desc, actual);
+    } else {
+        printf("  FAIL: %s (expected %lld, got %lld)\n", desc, expected, actual);
+        failures++;
+    }
+}
+
+static int run(GoUint64 waf, char *uri, char *body)
+{
+    GoUint64 tx = coraza_new_transaction(waf);
+    int status;
+
+    coraza_process_connection(tx, "192.0.2.1", 40000, "192.0.2.2", 80);
+    status = coraza_process_request_headers(tx, "POST", uri, "HTTP/1.1",
+        "[[\"Host\",\"example.com\"],[\"Content-Type\",\"application/x-www-form-urlencoded\"]]");
+    if (status == 0) {
+        status = coraza_process_request_body(tx, body, (int)strlen(body));
+    }
+    if (status == 0) {
+        status = coraza_process_response_headers(tx, 200, "[[\"Content-Type\",\"text/html\"]]");
+    }
+    if (status == 0) {
+        status = coraza_process_response_body(tx, "<html></html>", 13);
+    }
+
+    int blocked = -1;
+    int final = coraza_finalize(tx, &blocked);
+    check("finalize agrees with phase status", status, final);
+    check("blocked flag", status != 0, blocked);
+
+    char *rule = coraza_get_decisive_rule_json(tx);
+    check("decisive rule reported only when blocked", status != 0, rule != NULL);
+    free(rule);
+
+    coraza_free_transaction(tx);
+    return status;
+}
+
+/*
+ * Streams body in small chunks with no Content-Length, as a chunked request
+ * arrives, optionally forcing inspection of a body without a Content-Type.
+ */
+static int run_chunked(GoUint64 waf, char *headers, char *body, int force)
+{
+    GoUint64 tx = coraza_new_transaction(waf);
+    int status = coraza_process_request_headers(tx, "POST", "/", "HTTP/1.1", headers);
+
+    if (status == 0 && force) {
+        check("force body inspection", 0, coraza_set_force_request_body_inspection(tx, 1));
+    }
+    int len = (int)strlen(body);
+    for (int off = 0; status == 0 && off < len; off += 4) {
+        status = coraza_write_request_body(tx, body + off, len - off < 4 ? len - off : 4);
+    }
+    if (status == 0) {
+        status = coraza_process_request_body(tx, NULL, 0);
+    }
+
+    coraza_free_transaction(tx);
+    return status;
+}
+
+/*
+ * Sends a GET whose response has the given Content-Type and body.
+ */
+static int run_response(GoUint64 waf, char *headers, char *body)
+{
+    GoUint64 tx = coraza_new_transaction(waf);
+    int status = coraza_process_request_headers(tx, "GET", "/", "HTTP/1.1", "[]");
+
+    if (status == 0) {
+        status = coraza_process_request_body(tx, NULL, 0);
+    }
+    if (status == 0) {
+        status = coraza_process_response_headers(tx, 200, headers);
+    }
+    if (status == 0) {
+        status = coraza_process_response_body(tx, body, (int)strlen(body));
+    }
+
+    coraza_free_transaction(tx);
+    return status;
+}
+
+/*
+ * Sends a request with n headers, then a response with n headers if the
+ * request passes.
+ */
+static int run_header_flood(GoUint64 waf, int n, int response)
+{
+    size_t size = 2 + (size_t)n * 32;
+    char *headers = malloc(size);
+    size_t off = 0;
+
+    off += snprintf(headers + off, size - off, "[");
+    for (int i = 0; i < n; i++) {
+        off += snprintf(headers + off, size - off, "%s[\"X-H%d\",\"v\"]", i ? "," : "", i);
+    }
+    snprintf(headers + off, size - off, "]");
+
+    GoUint64 tx = coraza_new_transaction(waf);
+    int status = coraza_process_request_headers(tx, "GET", "/", "HTTP/1.1",
+        response ? "[]" : headers);
+    if (status == 0 && response) {
+        status = coraza_process_response_headers(tx, 200, headers);
+    }
+
+    coraza_free_transaction(tx);
+    free(headers);
+    return status;
+}
+
+int main(void)
+{
+    printf("=== Coraza bridge ABI smoke test ===\n");
+
+    GoUint64 waf = coraza_new_waf(
+        "SecRuleEngine On\n"
+        "SecRequestBodyAccess On\n"
+        "SecRule ARGS \"@contains attack\" \"id:1,phase:2,deny,status:403,msg:'attack'\"\n");
+    check("WAF created", 1, waf != 0);
+    if (waf == 0) {
+        char *err = coraza_last_error();
+        printf("  error: %s\n", err ? err : "(none)");
+        free(err);
+        return 1;
+    }
+
+    check("clean request passes", 0, run(waf, "/?q=hello", "name=value"));
+    check("attack in body is blocked", 403, run(waf, "/?q=hello", "name=attack"));
+    check("unknown transaction", -1, coraza_matched_rule_count(0));
+    check("chunked attack is blocked", 403, run_chunked(waf,
+        "[[\"Transfer-Encoding\",\"chunked\"],[\"Content-Type\",\"application/x-www-form-urlencoded\"]]",
+        "name=attack", 0));
+    check("untyped attack is not parsed", 0, run_chunked(waf,
+        "[[\"Transfer-Encoding\",\"chunked\"]]", "name=attack", 0));
+    check("forced untyped attack is blocked", 403, run_chunked(waf,
+        "[[\"Transfer-Encoding\",\"chunked\"]]", "name=attack", 1));
+
+    coraza_free_waf(waf);
+
+    waf = coraza_new_waf(
+        "SecRuleEngine On\n"
+        "SecResponseBodyAccess On\n"
+        "SecResponseBodyMimeType text/plain text/html\n"
+        "SecRule RESPONSE_BODY \"@contains secret\" \"id:2,phase:4,deny,status:502\"\n");
+    check("response WAF created", 1, waf != 0);
+    char *ndjson = "[[\"Content-Type\",\"application/x-ndjson\"]]";
+    check("leak in configured type is blocked", 502, run_response(waf,
+        "[[\"Content-Type\",\"text/plain\"]]", "{\"secret\":1}"));
+    check("leak in other type is not inspected", 0, run_response(waf, ndjson, "{\"secret\":1}"));
+    check("add response MIME type", 0, coraza_add_response_mime_type(waf, "Application/X-NDJSON"));
+    check("leak in added type is blocked", 502, run_response(waf, ndjson, "{\"secret\":1}"));
+    coraza_free_waf(waf);
+
+    waf = coraza_new_waf("SecRuleEngine On\n");
+    check("header limits set", 0, coraza_set_header_limits(waf, 100, 0));
+    check("request within header limit passes", 0, run_header_flood(waf, 100, 0));
+    check("request header flood is refused", 431, run_header_flood(waf, 50000, 0));
+    check("response header flood is refused", 502, run_header_flood(waf, 50000, 1));
+    coraza_free_waf(waf);
+
+    printf("=== Results: %s ===\n", failures == 0 ? "all passed" : "failures");
+    return failures == 0 ? 0 : 1;
+}
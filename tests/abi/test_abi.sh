@@ -0,0 +1,69 @@
+#!/usr/bin/env bash
+# ABI tests for the Coraza bridge: builds the shared library, which also
+# generates its C header, checks that the header and the Rust declarations in
+# crates/coraza/src/ffi.rs match the //export functions, and runs a C program
+# against the header and library.
+set -euo pipefail
+
+ROOT="$(cd "$(dirname "$0")/../.." && pwd)"
+GO_DIR="$ROOT/crates/coraza/go"
+FFI_RS="$ROOT/crates/coraza/src/ffi.rs"
+OUT="$(mktemp -d)"
+trap 'rm -rf "$OUT"' EXIT
+
+pass=0
+fail=0
+
+ok() {
+    echo "  PASS: $1"
+    pass=$((pass + 1))
+}
+
+not_ok() {
+    echo "  FAIL: $1"
+    fail=$((fail + 1))
+}
+
+echo "=== Coraza Bridge ABI Tests ==="
+echo
+
+echo "--- Build ---"
+(cd "$GO_DIR" && go build -buildmode=c-shared -o "$OUT/libcoraza_bridge.so" .)
+ok "shared library and header generated"
+
+echo
+echo "--- Exported symbols ---"
+exports="$(grep -h '^//export ' "$GO_DIR"/*.go | awk '{print $2}' | sort -u)"
+declared="$(grep -o 'pub fn coraza_[a-z0-9_]*' "$FFI_RS" | awk '{print $3}' | sort -u)"
+for name in $exports; do
+    if ! grep -q "^extern .* $name(" "$OUT/libcoraza_bridge.h"; then
+        not_ok "$name missing from generated header"
+    fi
+    if ! grep -qx "$name" <<<"$declared"; then
+        not_ok "$name not declared in ffi.rs"
+    fi
+done
+for name in $declared; do
+    if ! grep -qx "$name" <<<"$exports"; then
+        not_ok "$name declared in ffi.rs but not exported"
+    fi
+done
+[ "$fail" -eq 0 ] && ok "$(wc -w <<<"$exports") exports match header and ffi.rs"
+
+echo
+echo "--- C smoke test ---"
+if cc -Wall -Werror -o "$OUT/smoke" "$ROOT/tests/abi/smoke.c" \
+    -I"$OUT" -L"$OUT" -lcoraza_bridge -Wl,-rpath,"$OUT"; then
+    ok "smoke test compiles against generated header"
+    if "$OUT/smoke"; then
+        ok "smoke test"
+    else
+        not_ok "smoke test"
+    fi
+else
+    not_ok "smoke test compiles against generated header"
+fi
+
+echo
+echo "=== Results: $pass passed, $fail failed ==="
+[ "$fail" -eq 0 ]